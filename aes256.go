@@ -0,0 +1,73 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Length of the encryption key of an AES-256 secret.
+const aes256KeyLen = 32
+
+// ErrSecret256WrongLength is returned, possibly wrapped, for a secret
+// given to Encrypt256 or Decrypt256 that is not 48 bytes long.
+var ErrSecret256WrongLength = errors.New("fernet: AES-256 secret must be 48 bytes")
+
+// Encrypt256 is like Encrypt but encrypts with AES-256, for policies
+// that require it. secret must be the URL-safe base64 encoding of 48
+// bytes: a 16-byte signing key followed by a 32-byte encryption key.
+// This is an extension to the Fernet spec: the token has its own version
+// byte and must be decrypted with Decrypt256.
+func Encrypt256(msg, secret string, now time.Time) (string, error) {
+	signingKey, encryptionKey, err := extract256Keys(secret)
+	if err != nil {
+		return "", err
+	}
+	return sealKeys(&format{version: versionAES256}, nil, msg, signingKey, encryptionKey, now, randomIV)
+}
+
+// Decrypt256 decrypts a token created by Encrypt256. See Decrypt.
+func Decrypt256(token, secret string, now time.Time, ttl time.Duration) (string, error) {
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	signingKey, encryptionKey, err := extract256Keys(secret)
+	if err != nil {
+		return "", err
+	}
+	f := &format{version: versionAES256}
+	block, _ := aes.NewCipher(encryptionKey)
+	msg, ts, _, err := openToken(f, 0, tok, f.newMAC(signingKey), block)
+	if err != nil {
+		return "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// RandomSecret256 generates a secret suitable for use with Encrypt256.
+func RandomSecret256() (string, error) {
+	var b [keyLen + aes256KeyLen]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("fernet: failed to read from rand source: %v", err)
+	}
+	return base64.URLEncoding.EncodeToString(b[:]), nil
+}
+
+// Like extractKeys but for a secret for Encrypt256.
+func extract256Keys(secret string) (signing, encryption []byte, err error) {
+	keys, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrSecretNotBase64, err)
+	}
+	if len(keys) != keyLen+aes256KeyLen {
+		return nil, nil, ErrSecret256WrongLength
+	}
+	return keys[:keyLen], keys[keyLen:], nil
+}
@@ -0,0 +1,92 @@
+package fernet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncrypt256(t *testing.T) {
+	secret, err := RandomSecret256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, msg := range []string{"", "hello", "a message that spans more than one block"} {
+		tok, err := Encrypt256(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		got, err := Decrypt256(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if got != msg {
+			t.Fatalf("wrong message: got %q, want %q", got, msg)
+		}
+	}
+	tok, err := Encrypt256("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := Decrypt256(tok, secret, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	other, _ := RandomSecret256()
+	if _, err := Decrypt256(tok, other, now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+}
+
+// The key really is 256 bits: changing only the last byte of the
+// encryption key, which AES-128 would not use, changes the ciphertext.
+func TestEncrypt256UsesWholeKey(t *testing.T) {
+	var (
+		f   = &format{version: versionAES256}
+		now = time.Unix(1505321118, 0)
+		iv  = func(p []byte) error { return nil } // all zeros
+		key = make([]byte, keyLen+aes256KeyLen)
+	)
+	tok1, err := sealKeys(f, nil, "hello", key[:keyLen], key[keyLen:], now, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key[len(key)-1] = 1
+	tok2, err := sealKeys(f, nil, "hello", key[:keyLen], key[keyLen:], now, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw1, _ := base64.URLEncoding.DecodeString(tok1)
+	raw2, _ := base64.URLEncoding.DecodeString(tok2)
+	if bytes.Equal(raw1[msgOffset:len(raw1)-sha256.Size], raw2[msgOffset:len(raw2)-sha256.Size]) {
+		t.Fatal("the last byte of the encryption key does not affect the ciphertext")
+	}
+}
+
+// Tokens and secrets of the AES-128 and AES-256 profiles are not
+// interchangeable.
+func TestEncrypt256Profiles(t *testing.T) {
+	const secret128 = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	secret256, _ := RandomSecret256()
+	now := time.Now()
+	if _, err := Encrypt256("hello", secret128, now); !errors.Is(err, ErrSecret256WrongLength) {
+		t.Fatalf("Encrypt256 with a 32-byte secret: got error %v, want %v", err, ErrSecret256WrongLength)
+	}
+	if _, err := Encrypt("hello", secret256, now); !errors.Is(err, ErrSecretWrongLength) {
+		t.Fatalf("Encrypt with a 48-byte secret: got error %v, want %v", err, ErrSecretWrongLength)
+	}
+	tok256, _ := Encrypt256("hello", secret256, now)
+	if _, err := Decrypt(tok256, secret128, now, time.Minute); err == nil {
+		t.Fatal("Decrypt accepted an AES-256 token")
+	}
+	tok128, _ := Encrypt("hello", secret128, now)
+	if _, err := Decrypt256(tok128, secret256, now, time.Minute); err != ErrWrongVersion {
+		t.Fatalf("Decrypt256 of a standard token: got error %v, want %v", err, ErrWrongVersion)
+	}
+	if _, err := Decrypt256(tok256, "not base64!", now, time.Minute); !errors.Is(err, ErrSecretNotBase64) {
+		t.Fatalf("got error %v, want %v", err, ErrSecretNotBase64)
+	}
+}
@@ -0,0 +1,57 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// Returned when a caller-provided block cipher is not AES-sized.
+var errBlockSize = errors.New("fernet: block size must be 16 bytes")
+
+// EncryptWithBlock is like EncryptRawSecret but takes the encryption key
+// as a cipher.Block, which lets the AES encryption be done elsewhere,
+// such as in a hardware security module, so that the encryption key
+// never enters the process. block must implement AES-128 to produce a
+// standard token, though any cipher with a 16-byte block is accepted.
+// The token is signed in software with signingKey, which must be 16
+// bytes long.
+func EncryptWithBlock(msg string, signingKey []byte, block cipher.Block, now time.Time) (string, error) {
+	if len(signingKey) != keyLen {
+		return "", ErrSecretWrongLength
+	}
+	if block.BlockSize() != aes.BlockSize {
+		return "", errBlockSize
+	}
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return "", err
+	}
+	tok := make([]byte, paddedLen(len(msg))+fixedLen)
+	padString(tok[msgOffset:], msg)
+	return encryptPadded(tok, hmac.New(sha256.New, signingKey), block, &EncryptOptions{Now: now}, randomIV)
+}
+
+// DecryptWithBlock decrypts a token with the given signing key and
+// block cipher. The signature is verified before block is used. See
+// EncryptWithBlock and Decrypt.
+func DecryptWithBlock(token string, signingKey []byte, block cipher.Block, now time.Time, ttl time.Duration) (string, error) {
+	if len(signingKey) != keyLen {
+		return "", ErrSecretWrongLength
+	}
+	if block.BlockSize() != aes.BlockSize {
+		return "", errBlockSize
+	}
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	tok, err := opts.decodeToken(token)
+	if err != nil {
+		return "", err
+	}
+	msg, _, err := decryptToken(tok, hmac.New(sha256.New, signingKey), block, &opts)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
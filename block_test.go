@@ -0,0 +1,85 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A block cipher that counts its calls, standing in for one whose key
+// is held elsewhere.
+type countingBlock struct {
+	cipher.Block
+	calls int
+}
+
+func (b *countingBlock) Encrypt(dst, src []byte) { b.calls++; b.Block.Encrypt(dst, src) }
+func (b *countingBlock) Decrypt(dst, src []byte) { b.calls++; b.Block.Decrypt(dst, src) }
+
+func TestWithBlock(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	)
+	now := time.Date(1985, time.October, 26, 8, 20, 1, 0, time.UTC)
+	keys, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesBlock, err := aes.NewCipher(keys[keyLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &countingBlock{Block: aesBlock}
+	signingKey := keys[:keyLen]
+
+	// Tokens are interchangeable with those made from the secret.
+	msg, err := DecryptWithBlock(token, signingKey, block, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("got %q, want %q", msg, "hello")
+	}
+	tok, err := EncryptWithBlock("hello, world", signingKey, block, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := Decrypt(tok, secret, now, time.Minute); err != nil || msg != "hello, world" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello, world")
+	}
+	if block.calls == 0 {
+		t.Fatal("the block cipher was not used")
+	}
+
+	// The signature is checked before the block cipher is used.
+	block.calls = 0
+	otherKey := make([]byte, keyLen)
+	if _, err := DecryptWithBlock(token, otherKey, block, now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if block.calls != 0 {
+		t.Fatalf("block cipher called %d times for an unauthentic token", block.calls)
+	}
+	if _, err := DecryptWithBlock(token, signingKey, block, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+
+	if _, err := EncryptWithBlock("hello", signingKey[:15], block, now); err != ErrSecretWrongLength {
+		t.Fatalf("got error %v, want %v", err, ErrSecretWrongLength)
+	}
+	desBlock, err := des.NewCipher(make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncryptWithBlock("hello", signingKey, desBlock, now); err == nil {
+		t.Fatal("expected an error for an 8-byte block cipher")
+	}
+	if _, err := DecryptWithBlock(token, signingKey, desBlock, now, time.Minute); err == nil {
+		t.Fatal("expected an error for an 8-byte block cipher")
+	}
+}
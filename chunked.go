@@ -0,0 +1,546 @@
+package fernet
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"time"
+)
+
+// The chunked stream format encrypts a stream of any length as a
+// sequence of chunks, so it can be encrypted and decrypted using a
+// bounded amount of memory. It is an extension to the Fernet spec. A
+// stream consists of a header followed by one or more frames:
+//
+//	header = versionChunked || chunk size (4 bytes) || stream ID (16 bytes)
+//	frame  = versionChunked || timestamp || IV || ciphertext || HMAC
+//
+// Each frame is laid out like a raw Fernet token and holds one chunk of
+// the plaintext. Every chunk but the last holds exactly chunk size
+// bytes, so every frame but the last has the same length, which allows
+// random access. A frame's HMAC also covers the header, the chunk's
+// index, and whether it is the last chunk, so frames cannot be
+// reordered, moved between streams, or dropped from the end without
+// detection.
+
+const (
+	chunkedHeaderLen = 1 + 4 + 16
+
+	// DefaultChunkSize is a reasonable chunk size for NewChunkedWriter.
+	DefaultChunkSize = 64 << 10
+
+	// Limits the memory a reader allocates based on an unauthenticated
+	// header.
+	maxChunkSize = 16 << 20
+)
+
+var errTruncatedStream = errors.New("fernet: chunked stream is truncated")
+
+// ChunkError is returned when the HMAC of a frame of a chunked stream
+// is wrong. If only a few chunks fail, the stream was probably damaged
+// in storage; if they all do, the secret is probably wrong. The error
+// unwraps to ErrWrongHMAC.
+type ChunkError struct {
+	Index  int // index of the chunk, starting from zero
+	Offset int // offset in the encrypted stream of the start of its frame
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("%s in chunk %d at offset %d", ErrWrongHMAC, e.Index, e.Offset)
+}
+
+// Unwrap returns ErrWrongHMAC.
+func (e *ChunkError) Unwrap() error { return ErrWrongHMAC }
+
+// Holds the keys and header of a chunked stream.
+type chunkCodec struct {
+	signingKey []byte
+	block      cipher.Block
+	header     []byte
+	chunkSize  int
+	frameLen   int // length of a frame holding a full chunk
+}
+
+func newChunkCodec(secret string, header []byte) (*chunkCodec, error) {
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := aes.NewCipher(encryptionKey)
+	chunkSize := int(binary.BigEndian.Uint32(header[1:]))
+	return &chunkCodec{
+		signingKey: signingKey,
+		block:      block,
+		header:     header,
+		chunkSize:  chunkSize,
+		frameLen:   paddedLen(chunkSize) + fixedLen,
+	}, nil
+}
+
+// Returns an HMAC for the frame holding the specified chunk, with
+// everything but the frame itself already written.
+func (c *chunkCodec) newMAC(index uint64, final bool) hash.Hash {
+	mac := hmac.New(sha256.New, c.signingKey)
+	_, _ = mac.Write(c.header)
+	var b [9]byte
+	binary.BigEndian.PutUint64(b[:], index)
+	if final {
+		b[8] = 1
+	}
+	_, _ = mac.Write(b[:])
+	return mac
+}
+
+// Encrypts chunk into a frame, which is appended to dst.
+func (c *chunkCodec) seal(dst, chunk []byte, index uint64, final bool, now time.Time) ([]byte, error) {
+	n := len(dst)
+	frameLen := paddedLen(len(chunk)) + fixedLen
+	if cap(dst)-n < frameLen {
+		dst = append(make([]byte, 0, n+frameLen), dst...)
+	}
+	frame := dst[n : n+frameLen]
+	frame[0] = versionChunked
+	binary.BigEndian.PutUint64(frame[tsOffset:], uint64(now.Unix()))
+	if err := randomIV(frame[ivOffset:]); err != nil {
+		return nil, fmt.Errorf("fernet: failed to generate IV: %v", err)
+	}
+	macOffset := frameLen - sha256.Size
+	text := pad(frame[msgOffset:macOffset], chunk)
+	cipher.NewCBCEncrypter(c.block, frame[ivOffset:msgOffset]).CryptBlocks(text, text)
+	mac := c.newMAC(index, final)
+	_, _ = mac.Write(frame[:macOffset])
+	mac.Sum(frame[macOffset:macOffset])
+	return dst[:n+frameLen], nil
+}
+
+// Verifies a frame holding the specified chunk and decrypts it in place.
+func (c *chunkCodec) open(frame []byte, index uint64, final bool, now time.Time, ttl time.Duration) ([]byte, error) {
+	n := len(frame)
+	if n < fixedLen+aes.BlockSize || (n-fixedLen)%aes.BlockSize != 0 || n > c.frameLen {
+		return nil, errors.New("fernet: chunk has the wrong length")
+	}
+	if frame[0] != versionChunked {
+		return nil, ErrWrongVersion
+	}
+	macOffset := n - sha256.Size
+	if !c.verify(frame, index, final) {
+		// A full frame that was not written as the last chunk means
+		// that the frames after it are missing.
+		if final && n == c.frameLen && c.verify(frame, index, false) {
+			return nil, errTruncatedStream
+		}
+		return nil, &ChunkError{
+			Index:  int(index),
+			Offset: chunkedHeaderLen + int(index)*c.frameLen,
+		}
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint64(frame[tsOffset:])), 0)
+	if err := checkAge(ts, now, ttl); err != nil {
+		return nil, err
+	}
+	text := frame[msgOffset:macOffset]
+	cipher.NewCBCDecrypter(c.block, frame[ivOffset:msgOffset]).CryptBlocks(text, text)
+	msg := unpad(text)
+	if msg == nil {
+		return nil, ErrInvalidPadding
+	}
+	// Only the last chunk may be short; random access depends on it.
+	if !final && len(msg) != c.chunkSize {
+		return nil, errors.New("fernet: chunk has the wrong length")
+	}
+	return msg, nil
+}
+
+// Reports whether the HMAC of a frame holding the specified chunk is
+// correct.
+func (c *chunkCodec) verify(frame []byte, index uint64, final bool) bool {
+	macOffset := len(frame) - sha256.Size
+	mac := c.newMAC(index, final)
+	_, _ = mac.Write(frame[:macOffset])
+	var sum [sha256.Size]byte
+	return hmac.Equal(frame[macOffset:], mac.Sum(sum[:0]))
+}
+
+// Reads and checks the header of a chunked stream.
+func readChunkedHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, chunkedHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTruncatedStream
+		}
+		return nil, err
+	}
+	if header[0] != versionChunked {
+		return nil, ErrWrongVersion
+	}
+	if n := binary.BigEndian.Uint32(header[1:]); n == 0 || n > maxChunkSize {
+		return nil, errors.New("fernet: invalid chunk size")
+	}
+	return header, nil
+}
+
+// NewChunkedWriter returns a writer that encrypts everything written to
+// it as a chunked stream, which it writes to w. Plaintext is buffered
+// and encrypted chunkSize bytes at a time, so memory use is bounded by
+// the chunk size, which must be between 1 and 16 MiB. The stream is
+// complete only once Close returns. Use NewChunkedReader or
+// ChunkedReaderAt to decrypt the stream.
+func NewChunkedWriter(w io.Writer, secret string, now time.Time, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return nil, errors.New("fernet: invalid chunk size")
+	}
+	header := make([]byte, chunkedHeaderLen)
+	header[0] = versionChunked
+	binary.BigEndian.PutUint32(header[1:], uint32(chunkSize))
+	if _, err := io.ReadFull(rand.Reader, header[5:]); err != nil {
+		return nil, fmt.Errorf("fernet: failed to generate stream ID: %v", err)
+	}
+	c, err := newChunkCodec(secret, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &chunkedWriter{
+		w:     w,
+		c:     c,
+		now:   now,
+		buf:   make([]byte, 0, chunkSize),
+		frame: make([]byte, 0, c.frameLen),
+	}, nil
+}
+
+type chunkedWriter struct {
+	w      io.Writer
+	c      *chunkCodec
+	now    time.Time
+	buf    []byte // plaintext of the current chunk
+	frame  []byte
+	index  uint64 // index of the current chunk
+	err    error  // sticky write error
+	closed bool
+}
+
+func (cw *chunkedWriter) Write(p []byte) (int, error) {
+	if cw.closed {
+		return 0, errWriterClosed
+	}
+	written := 0
+	for len(p) > 0 && cw.err == nil {
+		// A full chunk is written only once more data arrives, since
+		// until then it might be the last.
+		if len(cw.buf) == cw.c.chunkSize {
+			cw.flush(false)
+			continue
+		}
+		n := copy(cw.buf[len(cw.buf):cap(cw.buf)], p)
+		cw.buf = cw.buf[:len(cw.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, cw.err
+}
+
+// ReadFrom implements io.ReaderFrom, so io.Copy reads plaintext from r
+// straight into the current chunk instead of through a separate buffer.
+// The stream is the same as if everything read had been passed to
+// Write.
+func (cw *chunkedWriter) ReadFrom(r io.Reader) (int64, error) {
+	if cw.closed {
+		return 0, errWriterClosed
+	}
+	var total int64
+	for cw.err == nil {
+		var (
+			n   int
+			err error
+		)
+		if len(cw.buf) < cw.c.chunkSize {
+			n, err = r.Read(cw.buf[len(cw.buf):cap(cw.buf)])
+			cw.buf = cw.buf[:len(cw.buf)+n]
+		} else {
+			// As in Write, a full chunk is written only once more data
+			// arrives, so read one byte to find out.
+			var b [1]byte
+			if n, err = r.Read(b[:]); n > 0 {
+				cw.flush(false)
+				cw.buf = append(cw.buf, b[0])
+			}
+		}
+		total += int64(n)
+		if err == io.EOF {
+			return total, cw.err
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, cw.err
+}
+
+// Encrypts and writes the current chunk.
+func (cw *chunkedWriter) flush(final bool) {
+	cw.frame, cw.err = cw.c.seal(cw.frame[:0], cw.buf, cw.index, final, cw.now)
+	if cw.err == nil {
+		_, cw.err = cw.w.Write(cw.frame)
+	}
+	cw.buf = cw.buf[:0]
+	cw.index++
+}
+
+func (cw *chunkedWriter) Close() error {
+	if cw.closed {
+		return errWriterClosed
+	}
+	cw.closed = true
+	if cw.err != nil {
+		return cw.err
+	}
+	// There is always a final chunk, even if it is empty.
+	cw.flush(true)
+	return cw.err
+}
+
+// NewChunkedReader reads the header of a chunked stream created by
+// NewChunkedWriter from r and returns a reader of the decrypted stream.
+// Each chunk is verified before any of its plaintext is returned, but
+// an error, including a truncated stream, may be reported only after
+// earlier chunks have been read, so callers must not act on the
+// plaintext until Read returns io.EOF.
+func NewChunkedReader(r io.Reader, secret string, now time.Time, ttl time.Duration) (io.Reader, error) {
+	header, err := readChunkedHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newChunkCodec(secret, header)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkedReader{
+		r:     bufio.NewReader(r),
+		c:     c,
+		now:   now,
+		ttl:   ttl,
+		frame: make([]byte, c.frameLen),
+	}, nil
+}
+
+type chunkedReader struct {
+	r     *bufio.Reader
+	c     *chunkCodec
+	now   time.Time
+	ttl   time.Duration
+	frame []byte
+	msg   []byte // unread plaintext of the current chunk
+	index uint64 // index of the next chunk
+	done  bool   // true once the final chunk has been read
+	err   error  // sticky error
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	for len(cr.msg) == 0 {
+		switch {
+		case cr.err != nil:
+			return 0, cr.err
+		case cr.done:
+			return 0, io.EOF
+		}
+		cr.msg, cr.err = cr.next()
+	}
+	n := copy(p, cr.msg)
+	cr.msg = cr.msg[n:]
+	return n, nil
+}
+
+// WriteTo implements io.WriterTo, so io.Copy writes each chunk's
+// plaintext to w as soon as it is verified and decrypted, without
+// copying it through a separate buffer. As with Read, an error may be
+// reported after earlier chunks have been written.
+func (cr *chunkedReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		if len(cr.msg) > 0 {
+			n, err := w.Write(cr.msg)
+			total += int64(n)
+			cr.msg = cr.msg[n:]
+			if err != nil {
+				return total, err
+			}
+		}
+		switch {
+		case cr.err != nil:
+			return total, cr.err
+		case cr.done:
+			return total, nil
+		}
+		cr.msg, cr.err = cr.next()
+	}
+}
+
+// Reads, verifies, and decrypts the next chunk.
+func (cr *chunkedReader) next() ([]byte, error) {
+	n, err := io.ReadFull(cr.r, cr.frame)
+	final := false
+	switch err {
+	case nil:
+		// A full frame is the last if nothing follows it.
+		if _, err := cr.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return nil, err
+		}
+	case io.ErrUnexpectedEOF:
+		final = true
+	case io.EOF:
+		return nil, errTruncatedStream
+	default:
+		return nil, err
+	}
+	msg, err := cr.c.open(cr.frame[:n], cr.index, final, cr.now, cr.ttl)
+	if err != nil {
+		return nil, err
+	}
+	cr.index++
+	cr.done = final
+	return msg, nil
+}
+
+// ChunkedReaderAt reads the header of a chunked stream created by
+// NewChunkedWriter from r and returns an io.ReaderAt of the decrypted
+// stream. Each call to ReadAt reads, verifies, and decrypts only the
+// chunks covering the requested range, so byte ranges of a large stream
+// can be served without decrypting all of it. ReadAt returns io.EOF
+// when the range extends past the end of the stream.
+func ChunkedReaderAt(r io.ReaderAt, secret string, now time.Time, ttl time.Duration) (io.ReaderAt, error) {
+	header, err := readChunkedHeader(io.NewSectionReader(r, 0, chunkedHeaderLen))
+	if err != nil {
+		return nil, err
+	}
+	c, err := newChunkCodec(secret, header)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkedReaderAt{r: r, c: c, now: now, ttl: ttl}, nil
+}
+
+type chunkedReaderAt struct {
+	r   io.ReaderAt
+	c   *chunkCodec
+	now time.Time
+	ttl time.Duration
+}
+
+func (ra *chunkedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("fernet: negative offset")
+	}
+	size := int64(ra.c.chunkSize)
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		msg, final, err := ra.readChunk(uint64(pos / size))
+		if err != nil {
+			return n, err
+		}
+		if i := pos % size; i < int64(len(msg)) {
+			n += copy(p[n:], msg[i:])
+		} else {
+			return n, io.EOF
+		}
+		if final && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Reads, verifies, and decrypts the specified chunk. If the stream ends
+// before the chunk, returns no plaintext and final set to true.
+func (ra *chunkedReaderAt) readChunk(index uint64) (msg []byte, final bool, err error) {
+	// Read one byte more than a full frame to see if another follows.
+	var n int
+	frame := make([]byte, ra.c.frameLen+1)
+	if off, ok := ra.frameOffset(index); ok {
+		n, err = ra.r.ReadAt(frame, off)
+	}
+	switch {
+	case err != nil && err != io.EOF:
+		return nil, false, err
+	case n == 0:
+		// Past the end; make sure the stream was not truncated by
+		// checking that the last frame was written as the last.
+		last, err := ra.lastFrame(index)
+		if err != nil {
+			return nil, false, err
+		}
+		_, lastFinal, err := ra.readChunk(last)
+		if err != nil {
+			return nil, false, err
+		}
+		if !lastFinal {
+			return nil, false, errTruncatedStream
+		}
+		return nil, true, nil
+	}
+	final = n <= ra.c.frameLen
+	if !final {
+		n = ra.c.frameLen
+	}
+	msg, err = ra.c.open(frame[:n], index, final, ra.now, ra.ttl)
+	return msg, final, err
+}
+
+// Returns the offset in the stream of the frame holding the specified
+// chunk. ok is false if the offset is too large to represent.
+func (ra *chunkedReaderAt) frameOffset(index uint64) (off int64, ok bool) {
+	const maxInt64 = 1<<63 - 1
+	if index > (maxInt64-chunkedHeaderLen)/uint64(ra.c.frameLen) {
+		return 0, false
+	}
+	return chunkedHeaderLen + int64(index)*int64(ra.c.frameLen), true
+}
+
+// Returns the index of the stream's last frame, given that the frame
+// holding the specified chunk is past the end. It binary searches for
+// the last frame that exists, reading a byte at a time, so an offset
+// far past the end costs only a few reads.
+func (ra *chunkedReaderAt) lastFrame(index uint64) (uint64, error) {
+	var (
+		b    [1]byte
+		rerr error
+	)
+	const maxInt = int(^uint(0) >> 1)
+	limit := maxInt
+	if index < uint64(maxInt) {
+		limit = int(index)
+	}
+	// The first index whose frame does not exist.
+	end := sort.Search(limit, func(i int) bool {
+		off, ok := ra.frameOffset(uint64(i))
+		if !ok {
+			return true
+		}
+		n, err := ra.r.ReadAt(b[:], off)
+		if err != nil && err != io.EOF && rerr == nil {
+			rerr = err
+		}
+		return n == 0
+	})
+	if rerr != nil {
+		return 0, rerr
+	}
+	if end == 0 {
+		return 0, errTruncatedStream
+	}
+	return uint64(end - 1), nil
+}
@@ -0,0 +1,349 @@
+package fernet
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Encrypts msg as a chunked stream.
+func encryptChunked(t *testing.T, msg []byte, secret string, now time.Time, chunkSize int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	cw, err := NewChunkedWriter(&buf, secret, now, chunkSize)
+	if err != nil {
+		t.Fatalf("NewChunkedWriter error: %s", err)
+	}
+	// Write in uneven pieces.
+	for p := msg; len(p) > 0; {
+		n := 1 + len(p)%13
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := cw.Write(p[:n]); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+		p = p[n:]
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, chunkSize := range []int{1, 16, 100} {
+		for _, n := range []int{0, 1, 15, 16, 17, 99, 100, 101, 250, 1000} {
+			msg := make([]byte, n)
+			rand.Read(msg)
+			stream := encryptChunked(t, msg, secret, now, chunkSize)
+			r, err := NewChunkedReader(bytes.NewReader(stream), secret, now, time.Minute)
+			if err != nil {
+				t.Fatalf("NewChunkedReader error: %s", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("chunkSize=%d n=%d: read error: %s", chunkSize, n, err)
+			}
+			if !bytes.Equal(got, msg) {
+				t.Fatalf("chunkSize=%d n=%d: wrong message", chunkSize, n)
+			}
+		}
+	}
+	if _, err := NewChunkedWriter(io.Discard, secret, now, 0); err == nil {
+		t.Fatal("expected an error for a zero chunk size")
+	}
+	if _, err := NewChunkedWriter(io.Discard, "bad", now, 16); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+// A reader that records the largest buffer it is asked to fill. It
+// does not implement io.WriterTo, so io.Copy must use the destination's
+// ReadFrom method if it has one.
+type maxReadReader struct {
+	r   io.Reader
+	max int
+}
+
+func (m *maxReadReader) Read(p []byte) (int, error) {
+	if len(p) > m.max {
+		m.max = len(p)
+	}
+	return m.r.Read(p)
+}
+
+// A writer whose ReadFrom method must not be called, since io.Copy
+// prefers the source's WriteTo method.
+type noReadFromWriter struct{ bytes.Buffer }
+
+func (w *noReadFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	return 0, errors.New("ReadFrom called")
+}
+
+func TestChunkedCopy(t *testing.T) {
+	const (
+		secret    = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		chunkSize = 100
+	)
+	now := time.Now()
+	for _, n := range []int{0, 1, 99, 100, 101, 200, 1000, 1001} {
+		msg := make([]byte, n)
+		rand.Read(msg)
+		var stream bytes.Buffer
+		cw, err := NewChunkedWriter(&stream, secret, now, chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := &maxReadReader{r: bytes.NewReader(msg)}
+		if written, err := io.Copy(cw, src); err != nil || written != int64(n) {
+			t.Fatalf("n=%d: copy returned (%d, %v)", n, written, err)
+		}
+		if src.max > chunkSize {
+			t.Fatalf("n=%d: read into a %d-byte buffer, so ReadFrom was not used", n, src.max)
+		}
+		if err := cw.Close(); err != nil {
+			t.Fatalf("n=%d: close error: %s", n, err)
+		}
+		// The stream has the same layout as one written with Write.
+		if want := len(encryptChunked(t, msg, secret, now, chunkSize)); stream.Len() != want {
+			t.Fatalf("n=%d: stream is %d bytes, want %d", n, stream.Len(), want)
+		}
+		r, err := NewChunkedReader(bytes.NewReader(stream.Bytes()), secret, now, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst noReadFromWriter
+		if read, err := io.Copy(&dst, r); err != nil || read != int64(n) {
+			t.Fatalf("n=%d: copy returned (%d, %v)", n, read, err)
+		}
+		if !bytes.Equal(dst.Bytes(), msg) {
+			t.Fatalf("n=%d: wrong message", n)
+		}
+	}
+
+	// No plaintext from a tampered chunk is written.
+	msg := make([]byte, 1000)
+	stream := encryptChunked(t, msg, secret, now, chunkSize)
+	stream[len(stream)-1] ^= 1
+	r, err := NewChunkedReader(bytes.NewReader(stream), secret, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dst noReadFromWriter
+	read, err := io.Copy(&dst, r)
+	if !errors.Is(err, ErrWrongHMAC) {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if read != 900 || dst.Len() != 900 {
+		t.Fatalf("copied %d bytes before the tampered chunk, want 900", read)
+	}
+}
+
+func TestChunkedTampering(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	msg := bytes.Repeat([]byte("0123456789"), 10)
+	stream := encryptChunked(t, msg, secret, now, 32)
+	frameLen := paddedLen(32) + fixedLen
+	other := encryptChunked(t, msg, secret, now, 32)
+
+	swapped := append([]byte(nil), stream...)
+	first := chunkedHeaderLen
+	copy(swapped[first:], stream[first+frameLen:first+2*frameLen])
+	copy(swapped[first+frameLen:], stream[first:first+frameLen])
+
+	spliced := append([]byte(nil), stream...)
+	copy(spliced[first:], other[first:first+frameLen])
+
+	flipped := append([]byte(nil), stream...)
+	flipped[first+frameLen+40] ^= 1
+
+	tests := []struct {
+		desc   string
+		stream []byte
+	}{
+		{"truncated at frame boundary", stream[:first+2*frameLen]},
+		{"truncated mid-frame", stream[:first+2*frameLen+20]},
+		{"header only", stream[:first]},
+		{"short header", stream[:first-1]},
+		{"swapped frames", swapped},
+		{"frame from another stream", spliced},
+		{"flipped bit", flipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r, err := NewChunkedReader(bytes.NewReader(tt.stream), secret, now, time.Minute)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			ra, err := ChunkedReaderAt(bytes.NewReader(tt.stream), secret, now, time.Minute)
+			if err == nil {
+				_, err = ra.ReadAt(make([]byte, len(msg)), 0)
+			}
+			if err == nil || err == io.EOF {
+				t.Fatalf("ReadAt: got error %v, want a verification error", err)
+			}
+		})
+	}
+	r, err := NewChunkedReader(bytes.NewReader(stream[:first+2*frameLen]), secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("NewChunkedReader error: %s", err)
+	}
+	if _, err := io.ReadAll(r); err != errTruncatedStream {
+		t.Fatalf("got error %v, want %v", err, errTruncatedStream)
+	}
+	r, _ = NewChunkedReader(bytes.NewReader(stream), secret, now.Add(time.Hour), time.Minute)
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestChunkedReaderAt(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 64, 100, 1000} {
+		msg := make([]byte, n)
+		rng.Read(msg)
+		stream := encryptChunked(t, msg, secret, now, 64)
+		ra, err := ChunkedReaderAt(bytes.NewReader(stream), secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("ChunkedReaderAt error: %s", err)
+		}
+		for i := 0; i < 200; i++ {
+			off := rng.Intn(n + 10)
+			p := make([]byte, rng.Intn(300))
+			got, err := ra.ReadAt(p, int64(off))
+			// Compute the expected result.
+			want := 0
+			if off < n {
+				want = n - off
+				if want > len(p) {
+					want = len(p)
+				}
+			}
+			if got != want {
+				t.Fatalf("n=%d: ReadAt(%d bytes, %d) = %d, want %d", n, len(p), off, got, want)
+			}
+			if want < len(p) && err != io.EOF {
+				t.Fatalf("n=%d: ReadAt(%d bytes, %d): got error %v, want %v", n, len(p), off, err, io.EOF)
+			}
+			if want == len(p) && err != nil && err != io.EOF {
+				t.Fatalf("n=%d: ReadAt(%d bytes, %d) error: %s", n, len(p), off, err)
+			}
+			if got > 0 && !bytes.Equal(p[:got], msg[off:off+got]) {
+				t.Fatalf("n=%d: ReadAt(%d bytes, %d) returned the wrong data", n, len(p), off)
+			}
+		}
+	}
+	ra, err := ChunkedReaderAt(bytes.NewReader(encryptChunked(t, []byte("hello"), secret, now, 64)), secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("ChunkedReaderAt error: %s", err)
+	}
+	if _, err := ra.ReadAt(make([]byte, 1), -1); err == nil {
+		t.Fatal("expected an error for a negative offset")
+	}
+}
+
+// A ReaderAt that counts its calls.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.calls++
+	return c.r.ReadAt(p, off)
+}
+
+// An offset far past the end of the stream costs a few reads, not one
+// per chunk, and still detects truncation.
+func TestChunkedReaderAtHugeOffset(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	stream := encryptChunked(t, bytes.Repeat([]byte("x"), 100), secret, now, 16)
+	frameLen := paddedLen(16) + fixedLen
+	truncated := stream[:chunkedHeaderLen+3*frameLen]
+	for _, off := range []int64{100, 101, 1 << 20, 64 << 20, 1 << 40, math.MaxInt64} {
+		for _, tt := range []struct {
+			stream []byte
+			want   error
+		}{
+			{stream, io.EOF},
+			{truncated, errTruncatedStream},
+		} {
+			r := &countingReaderAt{r: bytes.NewReader(tt.stream)}
+			ra, err := ChunkedReaderAt(r, secret, now, time.Minute)
+			if err != nil {
+				t.Fatalf("ChunkedReaderAt error: %s", err)
+			}
+			n, err := ra.ReadAt(make([]byte, 10), off)
+			if n != 0 || err != tt.want {
+				t.Fatalf("ReadAt(10 bytes, %d) = (%d, %v), want (0, %v)", off, n, err, tt.want)
+			}
+			if r.calls > 100 {
+				t.Fatalf("ReadAt(10 bytes, %d) made %d reads", off, r.calls)
+			}
+		}
+	}
+}
+
+func TestChunkError(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	msg := bytes.Repeat([]byte("0123456789"), 10)
+	stream := encryptChunked(t, msg, secret, now, 32)
+	frameLen := paddedLen(32) + fixedLen
+	for index := 0; index < 4; index++ {
+		off := chunkedHeaderLen + index*frameLen
+		corrupt := append([]byte(nil), stream...)
+		corrupt[off+msgOffset] ^= 0x10
+		want := &ChunkError{Index: index, Offset: off}
+
+		r, err := NewChunkedReader(bytes.NewReader(corrupt), secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("NewChunkedReader error: %s", err)
+		}
+		got, err := io.ReadAll(r)
+		var ce *ChunkError
+		if !errors.As(err, &ce) || *ce != *want {
+			t.Fatalf("chunk %d: got error %v, want %v", index, err, want)
+		}
+		if !errors.Is(err, ErrWrongHMAC) {
+			t.Fatalf("chunk %d: error %v does not match %v", index, err, ErrWrongHMAC)
+		}
+		// The chunks before the damaged one are intact.
+		if !bytes.Equal(got, msg[:32*index]) {
+			t.Fatalf("chunk %d: read %d bytes, want %d", index, len(got), 32*index)
+		}
+
+		ra, err := ChunkedReaderAt(bytes.NewReader(corrupt), secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("ChunkedReaderAt error: %s", err)
+		}
+		_, err = ra.ReadAt(make([]byte, len(msg)), 0)
+		if !errors.As(err, &ce) || *ce != *want {
+			t.Fatalf("chunk %d: ReadAt: got error %v, want %v", index, err, want)
+		}
+	}
+	// With the wrong secret, the first chunk fails.
+	r, err := NewChunkedReader(bytes.NewReader(stream), "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now, time.Minute)
+	if err != nil {
+		t.Fatalf("NewChunkedReader error: %s", err)
+	}
+	_, err = io.ReadAll(r)
+	var ce *ChunkError
+	if !errors.As(err, &ce) || ce.Index != 0 {
+		t.Fatalf("got error %v, want a ChunkError for chunk 0", err)
+	}
+}
@@ -0,0 +1,186 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// An Observer is notified of the outcome of each decryption performed by
+// a Decryptor, e.g. to export metrics. Implementations must be safe for
+// concurrent use and should return quickly.
+type Observer interface {
+	// ObserveDecrypt is called with the ID of the key whose HMAC
+	// matched the token, or "" if none did, and the error, if any. The
+	// ID is set for an expired token.
+	ObserveDecrypt(keyID string, err error)
+}
+
+// Decryptor decrypts tokens with the secrets in a KeyRing. It is meant
+// to be created once and shared, e.g. by HTTP middleware: it sets up
+// each secret's cipher only once and reuses its buffers, so it is
+// considerably cheaper than KeyRing.Decrypt. Secrets added to the key
+// ring are picked up automatically. A Decryptor is safe for concurrent
+// use.
+type Decryptor struct {
+	ring *KeyRing
+	obs  Observer
+
+	mu   sync.RWMutex
+	keys map[string]*Key // by key ID
+
+	bufs sync.Pool // of *decryptBuf
+
+	stats opStats
+}
+
+// Scratch space for decoding a token.
+type decryptBuf struct {
+	src, dst []byte
+}
+
+// NewDecryptor returns a Decryptor that uses the secrets in kr. If obs
+// is not nil, it is notified of every decryption.
+func NewDecryptor(kr *KeyRing, obs Observer) *Decryptor {
+	return &Decryptor{ring: kr, obs: obs, keys: make(map[string]*Key)}
+}
+
+// Warm sets up the cipher for every secret in the key ring, so that
+// the first tokens decrypted after startup do not pay for it. Secrets
+// whose cipher is already set up are skipped. Secrets added to the key
+// ring later are set up when first used, as usual.
+func (d *Decryptor) Warm() error {
+	for _, id := range d.ring.keyIDs() {
+		if _, err := d.key(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decrypt decrypts token, which may be a standard token or one created
+// by KeyRing.EncryptWithID. See KeyRing.Decrypt.
+func (d *Decryptor) Decrypt(token string, now time.Time, ttl time.Duration) (string, error) {
+	msg, id, err := d.decrypt(token, now, ttl)
+	d.stats.decrypted(err)
+	if d.obs != nil {
+		d.obs.ObserveDecrypt(id, err)
+	}
+	return msg, err
+}
+
+// Implements Decrypt, also returning the ID of the key used, if any.
+func (d *Decryptor) decrypt(token string, now time.Time, ttl time.Duration) (string, string, error) {
+	buf, _ := d.bufs.Get().(*decryptBuf)
+	if buf == nil {
+		buf = new(decryptBuf)
+	}
+	defer d.bufs.Put(buf)
+	buf.src = append(buf.src[:0], token...)
+	if n := base64.URLEncoding.DecodedLen(len(token)); cap(buf.dst) < n {
+		buf.dst = make([]byte, n)
+	}
+	n, err := base64.URLEncoding.Decode(buf.dst[:cap(buf.dst)], buf.src)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	tok := buf.dst[:n]
+	if len(tok) > tsOffset+tsLen && tok[0] == versionKeyID {
+		return d.decryptWithID(tok, now, ttl)
+	}
+	if len(tok) < fixedLen+aes.BlockSize {
+		return "", "", ErrTokenTooShort
+	}
+	if tok[0] != version {
+		return "", "", ErrWrongVersion
+	}
+	// Try each secret, newest first. A failed attempt leaves tok
+	// unchanged, since nothing is decrypted until the HMAC is verified.
+	ids := d.ring.keyIDs()
+	if len(ids) == 0 {
+		return "", "", errors.New("fernet: no secrets")
+	}
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	var firstErr error
+	for i := len(ids) - 1; i >= 0; i-- {
+		k, err := d.key(ids[i])
+		if err != nil {
+			return "", "", err
+		}
+		mac := k.getMAC()
+		msg, _, err := decryptToken(tok, mac, k.block, &opts)
+		k.macs.Put(mac)
+		if err == nil {
+			return string(msg), ids[i], nil
+		}
+		// An expired token is authentic, so no other secret will do.
+		var expired *ExpiredError
+		if errors.As(err, &expired) {
+			return "", ids[i], err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", "", firstErr
+}
+
+// Stats returns the number of calls to Decrypt so far and how many of
+// them failed. A Decryptor never encrypts, so encrypts is always zero.
+// See Fernet.Stats.
+func (d *Decryptor) Stats() (encrypts, decrypts, failures uint64) {
+	return d.stats.get()
+}
+
+// Decrypts a token created by KeyRing.EncryptWithID.
+func (d *Decryptor) decryptWithID(tok []byte, now time.Time, ttl time.Duration) (string, string, error) {
+	n := int(tok[tsOffset+tsLen])
+	if n > len(tok)-(tsOffset+tsLen+1) {
+		return "", "", ErrTokenTooShort
+	}
+	id := tok[tsOffset+tsLen+1 : tsOffset+tsLen+1+n]
+	k, err := d.key(string(id))
+	if err != nil {
+		return "", "", err
+	}
+	mac := k.getMAC()
+	defer k.macs.Put(mac)
+	msg, ts, _, err := openToken(&format{version: versionKeyID}, 1+n, tok, mac, k.block)
+	if err != nil {
+		return "", "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", "", err
+	}
+	return string(msg), string(id), nil
+}
+
+// Returns the Key for the secret with the given ID, creating and caching
+// it if necessary.
+func (d *Decryptor) key(id string) (*Key, error) {
+	d.mu.RLock()
+	k, ok := d.keys[id]
+	d.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	secret, ok := d.ring.secret(id)
+	if !ok {
+		return nil, errors.New("fernet: unknown key ID")
+	}
+	k, err := NewKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Another goroutine may have got here first.
+	if existing, ok := d.keys[id]; ok {
+		return existing, nil
+	}
+	d.keys[id] = k
+	return k, nil
+}
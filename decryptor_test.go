@@ -0,0 +1,147 @@
+package fernet
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An Observer that records what it observes.
+type recordingObserver struct {
+	mu   sync.Mutex
+	ids  []string
+	errs []error
+}
+
+func (o *recordingObserver) ObserveDecrypt(keyID string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ids = append(o.ids, keyID)
+	o.errs = append(o.errs, err)
+}
+
+func TestDecryptor(t *testing.T) {
+	const (
+		secret1 = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		secret2 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret3 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	now := time.Now()
+	kr := NewKeyRing()
+	if err := kr.Add("one", secret1); err != nil {
+		t.Fatal(err)
+	}
+	obs := new(recordingObserver)
+	d := NewDecryptor(kr, obs)
+
+	std1, _ := kr.Encrypt("std1", now)
+	id1, _ := kr.EncryptWithID("id1", now)
+	// Secrets added after the Decryptor is created are picked up.
+	if err := kr.Add("two", secret2); err != nil {
+		t.Fatal(err)
+	}
+	std2, _ := kr.Encrypt("std2", now)
+	id2, _ := kr.EncryptWithID("id2", now)
+	tests := []struct {
+		token, msg, id string
+	}{
+		{std1, "std1", "one"},
+		{id1, "id1", "one"},
+		{std2, "std2", "two"},
+		{id2, "id2", "two"},
+	}
+	for _, tt := range tests {
+		// Repeat to exercise the cached keys and pooled buffers.
+		for i := 0; i < 3; i++ {
+			msg, err := d.Decrypt(tt.token, now, time.Minute)
+			if err != nil {
+				t.Fatalf("%s: decrypt error: %s", tt.msg, err)
+			}
+			if msg != tt.msg {
+				t.Fatalf("wrong message: got %q, want %q", msg, tt.msg)
+			}
+			if got := obs.ids[len(obs.ids)-1]; got != tt.id {
+				t.Fatalf("%s: observed key ID %q, want %q", tt.msg, got, tt.id)
+			}
+		}
+	}
+
+	other, _ := Encrypt("other", secret3, now)
+	if _, err := d.Decrypt(other, now, time.Minute); err == nil {
+		t.Fatal("decrypted a token from an unknown secret")
+	}
+	if id, err := obs.ids[len(obs.ids)-1], obs.errs[len(obs.errs)-1]; id != "" || err == nil {
+		t.Fatalf("observed (%q, %v), want (\"\", an error)", id, err)
+	}
+	if _, err := d.Decrypt(std1, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := d.Decrypt(id1, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	foreign := NewKeyRing()
+	foreign.Add("three", secret3)
+	id3, _ := foreign.EncryptWithID("id3", now)
+	if _, err := d.Decrypt(id3, now, time.Minute); err == nil {
+		t.Fatal("decrypted a token with an unknown key ID")
+	}
+	for _, bad := range []string{"", "not base64!", std1[:40], id1[:len(id1)-4] + "AA=="} {
+		if _, err := d.Decrypt(bad, now, time.Minute); err == nil {
+			t.Fatalf("%q: expected an error", bad)
+		}
+	}
+	if _, err := NewDecryptor(NewKeyRing(), nil).Decrypt(std1, now, time.Minute); err == nil {
+		t.Fatal("expected an error with no secrets")
+	}
+}
+
+func BenchmarkDecryptorParallel(b *testing.B) {
+	kr := NewKeyRing()
+	kr.Add("one", "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=")
+	kr.Add("two", "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=")
+	now := time.Now()
+	tok, _ := kr.Encrypt("hello, world", now)
+	d := NewDecryptor(kr, nil)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := d.Decrypt(tok, now, time.Minute); err != nil {
+				b.Fatalf("decrypt error: %s", err)
+			}
+		}
+	})
+}
+
+func TestDecryptorWarm(t *testing.T) {
+	kr := NewKeyRing()
+	for id, secret := range map[string]string{
+		"one":   "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+		"two":   "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"three": "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+	} {
+		if err := kr.Add(id, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDecryptor(kr, nil)
+	if len(d.keys) != 0 {
+		t.Fatalf("got %d cached keys before Warm, want 0", len(d.keys))
+	}
+	if err := d.Warm(); err != nil {
+		t.Fatalf("Warm error: %s", err)
+	}
+	for _, id := range []string{"one", "two", "three"} {
+		if k := d.keys[id]; k == nil || k.block == nil {
+			t.Fatalf("no cipher for key %q after Warm", id)
+		}
+	}
+	// Warming again reuses the cached keys.
+	before := d.keys["one"]
+	if err := d.Warm(); err != nil {
+		t.Fatalf("Warm error: %s", err)
+	}
+	if d.keys["one"] != before {
+		t.Fatal("Warm replaced a cached key")
+	}
+}
@@ -0,0 +1,120 @@
+package fernet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test case 1 from RFC 5869, appendix A.
+func TestHKDF(t *testing.T) {
+	var (
+		ikm     = bytes.Repeat([]byte{0x0b}, 22)
+		salt, _ = hex.DecodeString("000102030405060708090a0b0c")
+		info, _ = hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+		prk     = "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5"
+		okm     = "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	)
+	gotPRK := hkdfExtract(salt, ikm)
+	if hex.EncodeToString(gotPRK) != prk {
+		t.Fatalf("wrong PRK: got %x, want %s", gotPRK, prk)
+	}
+	gotOKM := make([]byte, 42)
+	hkdfExpand(gotOKM, gotPRK, info)
+	if hex.EncodeToString(gotOKM) != okm {
+		t.Fatalf("wrong OKM: got %x, want %s", gotOKM, okm)
+	}
+}
+
+func TestEncryptDerived(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	for _, msg := range []string{"", "hello", "a message that spans more than one block"} {
+		tok, err := EncryptDerived(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		got, err := DecryptDerived(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if got != msg {
+			t.Fatalf("wrong message: got %q, want %q", got, msg)
+		}
+		if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+			t.Fatal("standard Decrypt accepted a derived-key token")
+		}
+	}
+	tok, err := EncryptDerived("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptDerived(tok, secret, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := DecryptDerived(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	// Changing the timestamp or IV changes the derived keys.
+	raw, _ := base64.URLEncoding.DecodeString(tok)
+	for _, i := range []int{tsOffset + tsLen - 1, ivOffset, msgOffset - 1} {
+		tampered := append([]byte(nil), raw...)
+		tampered[i] ^= 1
+		if _, err := DecryptDerived(base64.URLEncoding.EncodeToString(tampered), secret, now, time.Hour); err != ErrWrongHMAC {
+			t.Fatalf("byte %d changed: got error %v, want %v", i, err, ErrWrongHMAC)
+		}
+	}
+}
+
+// The same inputs always derive the same keys, and changing any of them
+// derives different ones, so each token has its own keys.
+func TestDeriveTokenKeys(t *testing.T) {
+	master := make([]byte, 32)
+	iv := make([]byte, 16)
+	base := deriveTokenKeys(master, 1505321118, iv)
+	if again := deriveTokenKeys(master, 1505321118, iv); again != base {
+		t.Fatal("derivation is not deterministic")
+	}
+	if bytes.Equal(base[:keyLen], make([]byte, keyLen)) || bytes.Equal(base[:keyLen], base[keyLen:]) {
+		t.Fatal("derived keys are degenerate")
+	}
+	otherIV := append([]byte(nil), iv...)
+	otherIV[15] = 1
+	otherMaster := append([]byte(nil), master...)
+	otherMaster[0] = 1
+	for desc, keys := range map[string][2 * keyLen]byte{
+		"timestamp": deriveTokenKeys(master, 1505321119, iv),
+		"IV":        deriveTokenKeys(master, 1505321118, otherIV),
+		"master":    deriveTokenKeys(otherMaster, 1505321118, iv),
+	} {
+		if keys == base {
+			t.Fatalf("changing the %s did not change the keys", desc)
+		}
+	}
+	// Two tokens of the same message at the same time use different
+	// keys, since their IVs differ.
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Unix(1505321118, 0)
+	fixedIV := func(b byte) func([]byte) error {
+		return func(p []byte) error {
+			for i := range p[:16] {
+				p[i] = b
+			}
+			return nil
+		}
+	}
+	tok1, _ := encryptDerived("hello", secret, now, fixedIV(1))
+	tok1Again, _ := encryptDerived("hello", secret, now, fixedIV(1))
+	tok2, _ := encryptDerived("hello", secret, now, fixedIV(2))
+	if tok1 != tok1Again {
+		t.Fatal("the same inputs produced different tokens")
+	}
+	raw1, _ := base64.URLEncoding.DecodeString(tok1)
+	raw2, _ := base64.URLEncoding.DecodeString(tok2)
+	if bytes.Equal(raw1[msgOffset:], raw2[msgOffset:]) {
+		t.Fatal("tokens with different IVs have the same ciphertext and HMAC")
+	}
+}
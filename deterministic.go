@@ -0,0 +1,34 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// EncryptDeterministic is like Encrypt but derives the IV from msg and
+// ts instead of generating it randomly, so identical inputs always
+// produce identical tokens, which can then be cached. The IV is the
+// first 16 bytes of HMAC-SHA256(signing key, msg || timestamp).
+//
+// This is not how the Fernet spec says to generate IVs, though the
+// tokens can be decrypted by any implementation. The trade-off is that
+// anyone can tell whether two tokens with the same timestamp contain
+// the same message; use Encrypt unless that is acceptable.
+func EncryptDeterministic(msg, secret string, ts time.Time) (string, error) {
+	signingKey, _, err := extractKeys(secret)
+	if err != nil {
+		return "", err
+	}
+	return encrypt(msg, secret, &EncryptOptions{Now: ts}, func(iv []byte) error {
+		var b [tsLen]byte
+		binary.BigEndian.PutUint64(b[:], uint64(ts.Unix()))
+		hash := hmac.New(sha256.New, signingKey)
+		_, _ = hash.Write([]byte(msg))
+		_, _ = hash.Write(b[:])
+		copy(iv[:aes.BlockSize], hash.Sum(nil))
+		return nil
+	})
+}
@@ -0,0 +1,45 @@
+package fernet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncryptDeterministic(t *testing.T) {
+	const (
+		secret1 = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		secret2 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+	)
+	ts := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	encrypt := func(msg, secret string, ts time.Time) string {
+		tok, err := EncryptDeterministic(msg, secret, ts)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		return tok
+	}
+	tok := encrypt("hello", secret1, ts)
+	if again := encrypt("hello", secret1, ts); again != tok {
+		t.Fatalf("identical inputs yielded different tokens: %q, %q", tok, again)
+	}
+	// Sub-second differences are not represented in the token.
+	if again := encrypt("hello", secret1, ts.Add(time.Millisecond)); again != tok {
+		t.Fatalf("identical inputs yielded different tokens: %q, %q", tok, again)
+	}
+	for _, other := range []string{
+		encrypt("hullo", secret1, ts),
+		encrypt("hello", secret1, ts.Add(time.Second)),
+		encrypt("hello", secret2, ts),
+	} {
+		if other == tok {
+			t.Fatalf("different inputs yielded the same token: %q", tok)
+		}
+	}
+	msg, err := Decrypt(tok, secret1, ts, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+	}
+}
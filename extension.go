@@ -0,0 +1,203 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// Version bytes of the non-spec token formats implemented by this
+// package. Standard Fernet decoders reject all of them.
+const (
+	versionSelfTTL  = 0xa1
+	versionKeyID    = 0xa2
+	versionMAC      = 0xa3
+	versionPadded   = 0xa4
+	versionHost     = 0xa5
+	versionChunked  = 0xa6
+	versionTimeless = 0xa7
+	versionPadding  = 0xa8
+	versionAES256   = 0xa9
+	versionSigned   = 0xaa
+	versionDerived  = 0xab
+	versionSplit    = 0xac
+	versionBundle   = 0xad
+)
+
+// Version bytes from versionReservedMin to versionReservedMax are
+// reserved for the formats above and future ones, so that a token of
+// one format can never be passed off as another, since they all share
+// the secret. EncryptOptions.Version and DecryptOptions.AcceptedVersions
+// may not use them. The range starts well above the spec's version so
+// that the bytes just after it stay free for callers' own versions.
+const (
+	versionReservedMin = 0xa0
+	versionReservedMax = 0xaf
+)
+
+// ErrReservedVersion is returned when EncryptOptions.Version or
+// DecryptOptions.AcceptedVersions holds a version byte reserved for the
+// package's own extensions to the spec.
+var ErrReservedVersion = errors.New("fernet: version is reserved for an extension")
+
+// Reports whether v is reserved for an extension.
+func isReservedVersion(v byte) bool {
+	return versionReservedMin <= v && v <= versionReservedMax
+}
+
+// Describes the token format used by an extension.
+type format struct {
+	version byte
+	mac     func() hash.Hash // HMAC hash function; SHA-256 if nil
+	aad     []byte           // associated data signed but not stored in the token
+	padding Padding          // block padding; PKCS #7 if nil
+}
+
+// Returns a new HMAC hash for f keyed with key. If f has associated
+// data, it has already been written to the hash, preceded by its length
+// so that it cannot be confused with the token that follows.
+func (f *format) newMAC(key []byte) hash.Hash {
+	var mac hash.Hash
+	if f.mac != nil {
+		mac = hmac.New(f.mac, key)
+	} else {
+		mac = hmac.New(sha256.New, key)
+	}
+	if f.aad != nil {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(f.aad)))
+		_, _ = mac.Write(n[:])
+		_, _ = mac.Write(f.aad)
+	}
+	return mac
+}
+
+// The helpers below implement the token formats used by extensions,
+// which all have the following layout:
+//
+//	version || timestamp || header || IV || ciphertext || HMAC
+//
+// where header is an extension-specific field whose length the decoder
+// must know in advance. The HMAC covers everything that precedes it,
+// plus the format's associated data, if any.
+
+// Encrypts and signs msg, returning the encoded token.
+func seal(f *format, header []byte, msg, secret string, now time.Time, genIV func([]byte) error) (string, error) {
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return "", err
+	}
+	return sealKeys(f, header, msg, signingKey, encryptionKey, now, genIV)
+}
+
+// Like seal but takes the decoded signing and encryption keys.
+func sealKeys(f *format, header []byte, msg string, signingKey, encryptionKey []byte, now time.Time, genIV func([]byte) error) (string, error) {
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return "", err
+	}
+	var padded []byte
+	textLen := paddedLen(len(msg))
+	if f.padding != nil {
+		padded = f.padding.Pad(nil, []byte(msg))
+		if len(padded) == 0 || len(padded)%aes.BlockSize != 0 {
+			return "", errors.New("fernet: padded message is not a whole number of blocks")
+		}
+		textLen = len(padded)
+	}
+	var (
+		mac    = f.newMAC(signingKey)
+		ivOff  = tsOffset + tsLen + len(header)
+		msgOff = ivOff + aes.BlockSize
+		tok    = make([]byte, msgOff+textLen+mac.Size())
+	)
+	tok[0] = f.version
+	binary.BigEndian.PutUint64(tok[tsOffset:], uint64(now.Unix()))
+	copy(tok[tsOffset+tsLen:], header)
+	if err := genIV(tok[ivOff:]); err != nil {
+		return "", fmt.Errorf("fernet: failed to generate IV: %v", err)
+	}
+	text := tok[msgOff : msgOff+textLen]
+	if padded != nil {
+		copy(text, padded)
+	} else {
+		padString(text, msg)
+	}
+	block, _ := aes.NewCipher(encryptionKey)
+	cipher.NewCBCEncrypter(block, tok[ivOff:msgOff]).CryptBlocks(text, text)
+	macOffset := len(tok) - mac.Size()
+	_, _ = mac.Write(tok[:macOffset])
+	mac.Sum(tok[macOffset:macOffset])
+	return encodeToken(tok), nil
+}
+
+// Reverses seal, returning the plaintext, the timestamp, and the header,
+// which has length headerLen. Does not check the timestamp.
+func open(f *format, headerLen int, token, secret string) (msg []byte, ts time.Time, header []byte, err error) {
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ts, nil, fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return nil, ts, nil, err
+	}
+	block, _ := aes.NewCipher(encryptionKey)
+	return openToken(f, headerLen, tok, f.newMAC(signingKey), block)
+}
+
+// Like open but takes the decoded token, which is decrypted in place,
+// and an HMAC and cipher keyed with the secret. mac must be a newly
+// created or reset hash returned by f.newMAC.
+func openToken(f *format, headerLen int, tok []byte, mac hash.Hash, block cipher.Block) (msg []byte, ts time.Time, header []byte, err error) {
+	var (
+		ivOff  = tsOffset + tsLen + headerLen
+		msgOff = ivOff + aes.BlockSize
+		n      = len(tok)
+	)
+	if n < msgOff+aes.BlockSize+mac.Size() {
+		return nil, ts, nil, ErrTokenTooShort
+	}
+	if tok[0] != f.version {
+		return nil, ts, nil, ErrWrongVersion
+	}
+	macOffset := n - mac.Size()
+	ciphertext := tok[msgOff:macOffset]
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ts, nil, ErrCiphertextLength
+	}
+	_, _ = mac.Write(tok[:macOffset])
+	if !hmac.Equal(tok[macOffset:], mac.Sum(nil)) {
+		return nil, ts, nil, ErrWrongHMAC
+	}
+	cipher.NewCBCDecrypter(block, tok[ivOff:msgOff]).CryptBlocks(ciphertext, ciphertext)
+	if f.padding != nil {
+		msg = f.padding.Unpad(ciphertext)
+	} else {
+		msg = unpad(ciphertext)
+	}
+	if msg == nil {
+		return nil, ts, nil, ErrInvalidPadding
+	}
+	ts = time.Unix(int64(binary.BigEndian.Uint64(tok[tsOffset:])), 0)
+	return msg, ts, tok[tsOffset+tsLen : ivOff], nil
+}
+
+// Checks that a token created at ts is neither expired nor too far in
+// the future.
+func checkAge(ts, now time.Time, ttl time.Duration) error {
+	md := Metadata{Timestamp: ts, Age: now.Sub(ts)}
+	switch {
+	case md.Age > ttl:
+		return &ExpiredError{Metadata: md}
+	case md.Age < -maxClockSkew:
+		return ErrClockSkew
+	}
+	return nil
+}
@@ -3,16 +3,22 @@
 package fernet
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -33,97 +39,619 @@ const (
 // token and the second sixteen are used to encrypt the message. now
 // should generally be set to the current time except during testing.
 func Encrypt(msg, secret string, now time.Time) (string, error) {
-	return encrypt(msg, secret, now, randomIV)
+	return encrypt(msg, secret, &EncryptOptions{Now: now}, randomIV)
+}
+
+// EncryptOptions holds the parameters for EncryptWithOptions.
+type EncryptOptions struct {
+	// Now is the time recorded in the token.
+	Now time.Time
+
+	// Rand is the source of the token's IV. If nil, crypto/rand.Reader
+	// is used.
+	Rand io.Reader
+
+	// Version is the token's version byte. If zero, the version in the
+	// Fernet spec (0x80) is used. Other Fernet implementations reject
+	// tokens with any other version, so change this only when working
+	// with extensions to the spec. The versions reserved for this
+	// package's own extensions, 0xa0 to 0xaf, are rejected with
+	// ErrReservedVersion; any other version, such as 0x81, may be used.
+	Version byte
+
+	// If true, encryption fails with ErrWeakIV if the generated IV is
+	// trivially predictable, such as all zeros or a sequence like the
+	// one in the spec's test vectors, which is a sign that the random
+	// number generator is broken. A good generator produces such an IV
+	// with negligible probability.
+	WarnOnWeakIV bool
+
+	// MaxMessageSize is the length of the longest message that may be
+	// encrypted. If zero, DefaultMaxMessageSize is used; if negative,
+	// only messages too long to represent in a token are rejected.
+	MaxMessageSize int
+
+	// If true, secrets that appear in published examples, such as the
+	// one in the Fernet spec's test vectors, are rejected with
+	// ErrKnownWeakSecret. Such a secret in production almost always
+	// means an example configuration was copied without change.
+	RejectKnownWeakSecrets bool
+}
+
+// ErrWeakIV is returned when EncryptOptions.WarnOnWeakIV is set and the
+// generated IV is trivially predictable.
+var ErrWeakIV = errors.New("fernet: generated IV is not random")
+
+// Reports whether iv is an arithmetic sequence, such as all zeros or
+// 0, 1, ..., 15.
+func isWeakIV(iv []byte) bool {
+	step := iv[1] - iv[0]
+	for i := 2; i < len(iv); i++ {
+		if iv[i]-iv[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultMaxMessageSize is the default limit on the length of a message.
+// A token is a third larger than its message and must be held in memory,
+// so much longer messages are better encrypted with NewChunkedWriter.
+const DefaultMaxMessageSize = 256 << 20
+
+// ErrMessageTooLarge is returned when a message is longer than the
+// maximum size.
+var ErrMessageTooLarge = errors.New("fernet: message too large")
+
+// Returns ErrMessageTooLarge if a message of n bytes exceeds limit (see
+// EncryptOptions.MaxMessageSize) or is too long for its token's length
+// to be computed without overflow.
+func checkMessageLen(n, limit int) error {
+	const maxInt = int(^uint(0) >> 1)
+	if limit == 0 {
+		limit = DefaultMaxMessageSize
+	}
+	if n > maxInt-fixedLen-aes.BlockSize || limit > 0 && n > limit {
+		return ErrMessageTooLarge
+	}
+	return nil
+}
+
+// EncryptWithOptions is like Encrypt but accepts additional parameters.
+func EncryptWithOptions(msg, secret string, opts EncryptOptions) (string, error) {
+	genIV := randomIV
+	if opts.Rand != nil {
+		genIV = func(p []byte) error {
+			_, err := io.ReadFull(opts.Rand, p[:aes.BlockSize])
+			return err
+		}
+	}
+	return encrypt(msg, secret, &opts, genIV)
 }
 
 // Accepts a func to set the IV so we can test with a specific vector.
-func encrypt(msg, secret string, now time.Time, genIV func([]byte) error) (string, error) {
+func encrypt(msg, secret string, opts *EncryptOptions, genIV func([]byte) error) (string, error) {
+	if err := checkMessageLen(len(msg), opts.MaxMessageSize); err != nil {
+		return "", err
+	}
 	// Extract keys from the secret.
-	signingKey, encryptionKey, err := extractKeys(secret)
+	k, err := secretCache.get(secret)
 	if err != nil {
 		return "", err
 	}
-	// Allocate the token buffer and fill in version and time.
+	if opts.RejectKnownWeakSecrets && isKnownWeakSecret(k.signing, k.encryption) {
+		return "", ErrKnownWeakSecret
+	}
+	// Allocate the token buffer and pad the plaintext into it.
 	tok := make([]byte, paddedLen(len(msg))+fixedLen)
+	padString(tok[msgOffset:], msg)
+	return encryptPadded(tok, hmac.New(sha256.New, k.signing), k.block, opts, genIV)
+}
+
+// Completes a token whose padded plaintext has already been written to
+// tok[msgOffset:]: fills in the version, time, and IV, encrypts the
+// plaintext in place, signs the token, and base64-encodes it. mac must
+// be a newly created or reset HMAC-SHA256 hash keyed with the signing
+// key, and block must be keyed with the encryption key.
+func encryptPadded(tok []byte, mac hash.Hash, block cipher.Block, opts *EncryptOptions, genIV func([]byte) error) (string, error) {
+	if err := encryptUnsigned(tok, block, opts, genIV); err != nil {
+		return "", err
+	}
+	// Compute the HMAC and write to the token.
+	macOffset := len(tok) - sha256.Size
+	_, _ = mac.Write(tok[:macOffset])
+	mac.Sum(tok[macOffset:macOffset])
+	// Base64 encode.
+	return encodeToken(tok), nil
+}
+
+// Like encryptPadded but leaves the token unsigned and unencoded.
+func encryptUnsigned(tok []byte, block cipher.Block, opts *EncryptOptions, genIV func([]byte) error) error {
+	// Fill in version and time.
+	if isReservedVersion(opts.Version) {
+		return ErrReservedVersion
+	}
 	tok[0] = version
-	binary.BigEndian.PutUint64(tok[tsOffset:], uint64(now.Unix()))
+	if opts.Version != 0 {
+		tok[0] = opts.Version
+	}
+	binary.BigEndian.PutUint64(tok[tsOffset:], uint64(opts.Now.Unix()))
 	// Generate the IV.
 	if err := genIV(tok[ivOffset:]); err != nil {
-		return "", fmt.Errorf("fernet: failed to generate IV: %v", err)
+		return fmt.Errorf("fernet: failed to generate IV: %v", err)
 	}
 	iv := tok[ivOffset : ivOffset+aes.BlockSize]
-	// Pad the plaintext and encrypt it in place.
-	text := pad(tok[msgOffset:], []byte(msg))
-	block, _ := aes.NewCipher(encryptionKey)
+	if opts.WarnOnWeakIV && isWeakIV(iv) {
+		return ErrWeakIV
+	}
+	// Encrypt the plaintext in place.
+	text := tok[msgOffset : len(tok)-sha256.Size]
 	cipher.NewCBCEncrypter(block, iv).CryptBlocks(text, text)
-	// Compute the HMAC and write to the token.
-	macOffset := len(tok) - sha256.Size
-	hash := hmac.New(sha256.New, signingKey)
-	_, _ = hash.Write(tok[:macOffset])
-	hash.Sum(tok[macOffset:macOffset])
-	// Base64 encode.
-	return base64.URLEncoding.EncodeToString(tok), nil
+	return nil
+}
+
+// Tokens at least this long are encoded by encodeToken without an
+// intermediate buffer. Below it, the buffer costs less than the
+// streaming encoder's setup.
+const streamEncodeThreshold = 4 << 10
+
+// Base64-encodes tok. EncodeToString encodes into a buffer and then
+// copies it into the string; for a large token, encodeToken saves the
+// copy by encoding straight into the string's memory.
+func encodeToken(tok []byte) string {
+	if len(tok) < streamEncodeThreshold {
+		return base64.URLEncoding.EncodeToString(tok)
+	}
+	var sb strings.Builder
+	sb.Grow(base64.URLEncoding.EncodedLen(len(tok)))
+	enc := base64.NewEncoder(base64.URLEncoding, &sb)
+	_, _ = enc.Write(tok) // writes to a strings.Builder never fail
+	_ = enc.Close()
+	return sb.String()
 }
 
 // Decrypt is the reverse of encrypt. Given a token returned by Encrypt,
 // the same secret, the current time, and a TTL, returns the original
 // message unless either of the following is true: the token has been
 // tampered with, or the TTL has elapsed since the token was generated.
+//
+// The empty string is a valid message, so callers must check the error,
+// not the message, to determine whether the token is valid.
+//
+// The token's age is measured by wall-clock time, since its timestamp
+// has no monotonic clock reading, so a now from time.Now gives the same
+// result as the same time without one.
+//
+// No part of the ciphertext is decrypted until the token's HMAC has been
+// verified, so no plaintext, not even a partial message, is ever
+// returned for a token that fails verification. This holds for every
+// decryption function in this package except DecryptForensic.
 func Decrypt(token, secret string, now time.Time, ttl time.Duration) (string, error) {
-	// Base64-decode the token.
-	tok, err := base64.URLEncoding.DecodeString(token)
+	return DecryptWithOptions(token, secret, DecryptOptions{Now: now, TTL: ttl})
+}
+
+// DecryptOptions holds the parameters for DecryptWithOptions.
+type DecryptOptions struct {
+	// Now is the time against which the token's age is measured.
+	Now time.Time
+
+	// TTL is the maximum age of a valid token. By default the boundary
+	// is inclusive: a token whose age is exactly TTL is still valid,
+	// and it expires only once its age exceeds TTL, as in the Fernet
+	// spec and the Python implementation.
+	TTL time.Duration
+
+	// If true, the boundary is exclusive instead: a token expires as
+	// soon as its age reaches TTL, so one whose age is exactly TTL is
+	// rejected. This matches implementations that treat a token as
+	// valid only while its age is less than TTL.
+	ExclusiveExpiry bool
+
+	// If non-zero, DecryptWithOptions fails with ErrTTLTooShort if TTL is
+	// less than MinTTL, unless TTL is NoTTL. This guards against a TTL
+	// misconfigured as zero or a few milliseconds, which would otherwise
+	// reject every token.
+	MinTTL time.Duration
+
+	// If non-zero, tokens are rejected with an *ExpiredError once Now is
+	// after Expiry, regardless of when they were issued. This suits
+	// expiry that is managed externally, such as the end of a
+	// subscription. TTL is still enforced; see DecryptUntil to rely on
+	// Expiry alone. ExclusiveExpiry applies to Expiry as it does to TTL.
+	Expiry time.Time
+
+	// If non-zero, tokens issued before NotBefore are rejected with
+	// ErrRevokedByCutoff regardless of TTL. This is useful for forcing
+	// everyone to reauthenticate after a security incident.
+	NotBefore time.Time
+
+	// MaxClockSkew is how far in the future a token's timestamp may be.
+	// If nil, the default of one hour is used. A pointer to zero rejects
+	// any token from the future, which suits tightly-synchronized clocks.
+	MaxClockSkew *time.Duration
+
+	// If true, the token must be canonically encoded: Go's base64
+	// decoder ignores newlines and any unused bits in the final
+	// character, so several strings can decode to the same token. This
+	// matters if, for example, tokens are deduplicated as strings.
+	StrictBase64 bool
+
+	// If true, the secret is hex-encoded instead of base64-encoded.
+	// See SecretFromHex.
+	HexSecret bool
+
+	// SecretEncoding is the encoding of the secret, if it differs from
+	// the URL-safe base64 encoding that Encrypt requires for both the
+	// secret and the token. It has no effect on the token and is
+	// ignored if HexSecret is true.
+	SecretEncoding *base64.Encoding
+
+	// AcceptedVersions lists the version bytes a valid token may have.
+	// If empty, only the version in the Fernet spec (0x80) is accepted.
+	// This lets verifiers accept tokens minted with a different
+	// EncryptOptions.Version while a migration is rolled out. Tokens of
+	// every listed version must use the standard token layout, so the
+	// versions reserved for this package's own extensions, 0xa0 to
+	// 0xaf, are rejected with ErrReservedVersion. Other versions, such
+	// as 0x81, may be listed alongside 0x80.
+	AcceptedVersions []byte
+
+	// If true, secrets that appear in published examples are rejected
+	// with ErrKnownWeakSecret. See EncryptOptions.
+	RejectKnownWeakSecrets bool
+}
+
+// Errors returned when a token fails verification. Other errors, such as
+// ErrExpired, may also be returned. Some errors add details to these, so
+// use errors.Is to test for them.
+var (
+	ErrTokenNotBase64   = errors.New("fernet: failed to decode token")
+	ErrTokenTooShort    = errors.New("fernet: token is too short")
+	ErrWrongVersion     = errors.New("fernet: wrong version")
+	ErrCiphertextLength = errors.New("fernet: ciphertext is not a multiple of the block size")
+	ErrWrongHMAC        = errors.New("fernet: wrong HMAC")
+	ErrClockSkew        = errors.New("fernet: clock skew")
+	ErrInvalidPadding   = errors.New("fernet: invalid padding")
+)
+
+// ErrRevokedByCutoff is returned when a token was issued before the
+// NotBefore time given in DecryptOptions.
+var ErrRevokedByCutoff = errors.New("fernet: token was issued before the cutoff")
+
+// ErrTTLTooShort is returned by DecryptWithOptions when the TTL is
+// less than DecryptOptions.MinTTL.
+var ErrTTLTooShort = errors.New("fernet: TTL is below the minimum")
+
+// NoTTL is a TTL that disables the TTL check, so that tokens never
+// expire however old they are.
+const NoTTL time.Duration = math.MaxInt64
+
+// MaxSafeTTL returns the largest TTL that is enforced exactly. A
+// token's age is a time.Duration, which cannot exceed about 292 years;
+// the age of an older token, or one with a corrupt but authentic
+// timestamp, saturates at the maximum Duration rather than overflowing.
+// Every TTL up to MaxSafeTTL expires such a token. The only larger TTL
+// is the maximum Duration itself, which is NoTTL: a caller passing
+// math.MaxInt64 to mean "never expires" gets exactly that, whatever
+// the token's age and even with ExclusiveExpiry.
+func MaxSafeTTL() time.Duration {
+	return NoTTL - 1
+}
+
+// ErrTooOld is returned by DecryptMinIssued when a token was issued
+// before the required time.
+var ErrTooOld = errors.New("fernet: token was issued too early")
+
+// DecryptMinIssued is like Decrypt but also rejects the token with
+// ErrTooOld if its authenticated timestamp is before minIssued, which
+// supports policies such as accepting only tokens issued since the
+// user last changed their password. Timestamps have a resolution of one
+// second, so a token issued in the same second as minIssued is rejected
+// if minIssued has a fractional second. A zero minIssued disables the
+// check.
+func DecryptMinIssued(token, secret string, now, minIssued time.Time, ttl time.Duration) (string, error) {
+	msg, err := DecryptWithOptions(token, secret, DecryptOptions{Now: now, TTL: ttl, NotBefore: minIssued})
+	if err == ErrRevokedByCutoff {
+		err = ErrTooOld
+	}
+	return msg, err
+}
+
+// DecryptWithOptions is like Decrypt but accepts additional parameters.
+func DecryptWithOptions(token, secret string, opts DecryptOptions) (string, error) {
+	if opts.MinTTL != 0 && opts.TTL < opts.MinTTL && opts.TTL != NoTTL {
+		return "", ErrTTLTooShort
+	}
+	msg, _, err := decrypt(token, secret, &opts)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// Metadata describes an authentic token.
+type Metadata struct {
+	Timestamp time.Time     // when the token was created
+	Age       time.Duration // how long before now the token was created
+}
+
+// ErrExpired is returned when a token's TTL has elapsed. The error is
+// always an *ExpiredError, which also reports the token's age.
+var ErrExpired = errors.New("fernet: token has expired")
+
+// ExpiredError is the error returned when an otherwise valid token has
+// expired. Because the token's signature is verified before its TTL is
+// checked, the timestamp is known to be authentic.
+type ExpiredError struct {
+	Metadata Metadata
+}
+
+func (e *ExpiredError) Error() string { return ErrExpired.Error() }
+
+// Unwrap returns ErrExpired, so errors.Is(err, ErrExpired) reports
+// whether err is an *ExpiredError.
+func (e *ExpiredError) Unwrap() error { return ErrExpired }
+
+// DecryptMetadata is like Decrypt but also returns the token's metadata.
+// If the token has expired, the error is an *ExpiredError, from which
+// the metadata can be retrieved with errors.As; no plaintext is ever
+// returned for an expired token.
+func DecryptMetadata(token, secret string, now time.Time, ttl time.Duration) (string, Metadata, error) {
+	msg, md, err := decrypt(token, secret, &DecryptOptions{Now: now, TTL: ttl})
+	if err != nil {
+		return "", md, err
+	}
+	return string(msg), md, nil
+}
+
+// DecryptUntil is like Decrypt but, instead of a TTL, takes the absolute
+// time at which the token expires: it is rejected with an *ExpiredError
+// if now is after expiry, regardless of when it was issued. To enforce
+// both a TTL and an expiry time, use DecryptWithOptions.
+func DecryptUntil(token, secret string, now, expiry time.Time) (string, error) {
+	return DecryptWithOptions(token, secret, DecryptOptions{Now: now, TTL: NoTTL, Expiry: expiry})
+}
+
+// Reports whether opts.Now is past opts.Expiry, if it is set.
+func (opts *DecryptOptions) pastExpiry() bool {
+	if opts.Expiry.IsZero() {
+		return false
+	}
+	return opts.Now.After(opts.Expiry) || opts.ExclusiveExpiry && opts.Now.Equal(opts.Expiry)
+}
+
+// Returns opts.MaxClockSkew or the default if it is nil.
+func (opts *DecryptOptions) maxClockSkew() time.Duration {
+	if opts.MaxClockSkew != nil {
+		return *opts.MaxClockSkew
+	}
+	return maxClockSkew
+}
+
+// IsExpiredAuthentic reports whether token is authentic but expired,
+// which is useful for a refresh endpoint that should accept only such
+// tokens. It returns false if the token is valid and unexpired, and an
+// error if the token is not authentic or is otherwise invalid.
+func IsExpiredAuthentic(token, secret string, now time.Time, ttl time.Duration) (bool, error) {
+	_, md, err := decrypt(token, secret, &DecryptOptions{Now: now, TTL: NoTTL})
+	if err != nil {
+		return false, err
+	}
+	return md.Age > ttl, nil
+}
+
+// Returns the signing key and a cipher keyed with the encryption key,
+// respecting opts.HexSecret, opts.SecretEncoding, and
+// opts.RejectKnownWeakSecrets. Only secrets in the default encoding are
+// cached.
+func (opts *DecryptOptions) keys(secret string) (signing []byte, block cipher.Block, err error) {
+	var encryption []byte
+	if opts.HexSecret || opts.SecretEncoding != nil {
+		if signing, encryption, err = opts.decodeKeys(secret); err != nil {
+			return nil, nil, err
+		}
+		block, _ = aes.NewCipher(encryption)
+	} else {
+		k, err := secretCache.get(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		signing, encryption, block = k.signing, k.encryption, k.block
+	}
+	if opts.RejectKnownWeakSecrets && isKnownWeakSecret(signing, encryption) {
+		return nil, nil, ErrKnownWeakSecret
+	}
+	return signing, block, nil
+}
+
+// Like extractKeys but respects opts.HexSecret and opts.SecretEncoding.
+func (opts *DecryptOptions) decodeKeys(secret string) (signing, encryption []byte, err error) {
+	switch {
+	case opts.HexSecret:
+		if secret, err = SecretFromHex(secret); err != nil {
+			return nil, nil, err
+		}
+	case opts.SecretEncoding != nil:
+		keys, err := decodeSecret(opts.SecretEncoding, secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return keys[:keyLen], keys[keyLen:], nil
+	}
+	return extractKeys(secret)
+}
+
+// Implements DecryptWithOptions. The returned metadata is valid if err
+// is nil or an *ExpiredError. The plaintext is nil if err is not nil.
+func decrypt(token, secret string, opts *DecryptOptions) ([]byte, Metadata, error) {
+	// Decode the token and check its length and version.
+	tok, err := opts.decodeToken(token)
 	if err != nil {
-		return "", fmt.Errorf("fernet: failed to decode token: %v", err)
+		return nil, Metadata{}, err
 	}
 	// Extract keys from the secret.
-	signingKey, encryptionKey, err := extractKeys(secret)
+	signingKey, block, err := opts.keys(secret)
 	if err != nil {
-		return "", err
+		return nil, Metadata{}, err
 	}
-	// To simplify bounds checking, make sure we have enough data.
-	if minLen := fixedLen + aes.BlockSize; len(tok) < minLen {
-		return "", errors.New("fernet: token is too short")
+	return decryptToken(tok, hmac.New(sha256.New, signingKey), block, opts)
+}
+
+// Like decodeToken but respects opts.StrictBase64 and
+// opts.AcceptedVersions.
+func (opts *DecryptOptions) decodeToken(token string) ([]byte, error) {
+	var (
+		tok []byte
+		err error
+	)
+	for _, v := range opts.AcceptedVersions {
+		if isReservedVersion(v) {
+			return nil, ErrReservedVersion
+		}
 	}
-	// Check the version.
-	if tok[0] != version {
-		return "", errors.New("fernet: wrong version")
+	if len(opts.AcceptedVersions) == 0 {
+		tok, err = decodeToken(token)
+	} else if tok, err = decodeTokenAnyVersion(token); err == nil && bytes.IndexByte(opts.AcceptedVersions, tok[0]) < 0 {
+		err = ErrWrongVersion
 	}
-	// Extract the timestamp and ensure token has not expired. The
-	// timestamp is a 64-bit big-endian integer.
-	t := time.Unix(int64(binary.BigEndian.Uint64(tok[tsOffset:])), 0)
-	switch tdiff := now.Sub(t); {
-	case tdiff > ttl:
-		return "", errors.New("fernet: token has expired")
-	case tdiff < -maxClockSkew:
-		return "", errors.New("fernet: clock skew")
+	if err != nil {
+		return nil, err
 	}
+	// Reject non-canonical encodings if asked. Go's decoder is lenient,
+	// so the only reliable test is to re-encode the token.
+	if opts.StrictBase64 && base64.URLEncoding.EncodeToString(tok) != token {
+		return nil, errors.New("fernet: token is not canonically encoded")
+	}
+	return tok, nil
+}
+
+// Verifies tok, a token returned by decodeToken, and decrypts it in
+// place. mac must be a newly created or reset HMAC-SHA256 hash keyed
+// with the signing key, and block must be keyed with the encryption key.
+// See decrypt.
+func decryptToken(tok []byte, mac hash.Hash, block cipher.Block, opts *DecryptOptions) ([]byte, Metadata, error) {
+	text, md, err := decryptTokenPadded(tok, mac, block, opts)
+	if err != nil {
+		return nil, md, err
+	}
+	if p := unpad(text); p != nil {
+		return p, md, nil
+	}
+	return nil, Metadata{}, ErrInvalidPadding
+}
+
+// Like decryptToken but returns the plaintext with its padding.
+func decryptTokenPadded(tok []byte, mac hash.Hash, block cipher.Block, opts *DecryptOptions) ([]byte, Metadata, error) {
 	var (
 		n          = len(tok)
-		iv         = tok[ivOffset : ivOffset+aes.BlockSize]
 		ciphertext = tok[msgOffset : n-sha256.Size]
 		macOffset  = n - sha256.Size
 		msgMAC     = tok[macOffset:]
 	)
 	// CBC mode always works in whole blocks.
 	if len(ciphertext)%aes.BlockSize != 0 {
-		return "", errors.New("fernet: ciphertext is not a multiple of the block size")
+		return nil, Metadata{}, ErrCiphertextLength
 	}
 	// Verify the HMAC signature.
 	var expectedMAC [sha256.Size]byte
-	hash := hmac.New(sha256.New, signingKey)
-	_, _ = hash.Write(tok[:macOffset])
-	hash.Sum(expectedMAC[:0])
+	_, _ = mac.Write(tok[:macOffset])
+	mac.Sum(expectedMAC[:0])
 	if !hmac.Equal(msgMAC, expectedMAC[0:]) {
-		return "", errors.New("fernet: wrong HMAC")
+		return nil, Metadata{}, ErrWrongHMAC
+	}
+	return decryptVerified(tok, block, opts)
+}
+
+// Checks the age of tok, a token whose signature has been verified,
+// and decrypts it in place, returning the plaintext with its padding.
+func decryptVerified(tok []byte, block cipher.Block, opts *DecryptOptions) ([]byte, Metadata, error) {
+	var (
+		iv         = tok[ivOffset : ivOffset+aes.BlockSize]
+		ciphertext = tok[msgOffset : len(tok)-sha256.Size]
+	)
+	// Extract the now-authenticated timestamp and ensure token has not
+	// expired. The timestamp is a 64-bit big-endian integer.
+	t := time.Unix(int64(binary.BigEndian.Uint64(tok[tsOffset:])), 0)
+	md := Metadata{Timestamp: t, Age: opts.Now.Sub(t)}
+	switch {
+	case opts.TTL != NoTTL && (md.Age > opts.TTL || opts.ExclusiveExpiry && md.Age == opts.TTL), opts.pastExpiry():
+		return nil, md, &ExpiredError{Metadata: md}
+	case md.Age < -opts.maxClockSkew():
+		return nil, Metadata{}, ErrClockSkew
+	}
+	if !opts.NotBefore.IsZero() && t.Before(opts.NotBefore) {
+		return nil, Metadata{}, ErrRevokedByCutoff
+	}
+	// Decrypt the ciphertext in place, since tok is ours to overwrite.
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return ciphertext, md, nil
+}
+
+// DecryptNoUnpad is like Decrypt but returns the decrypted message with
+// its PKCS #7 padding still attached, exactly as decrypted, which helps
+// diagnose padding problems in other implementations. The token is
+// fully verified first, but the padding is not checked, so the result
+// is always a non-empty multiple of 16 bytes and callers must remove the
+// padding themselves.
+func DecryptNoUnpad(token, secret string, now time.Time, ttl time.Duration) ([]byte, error) {
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	tok, err := opts.decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return nil, err
 	}
-	// Decrypt the ciphertext and return the unpadded message.
-	plaintext := make([]byte, len(ciphertext))
 	block, _ := aes.NewCipher(encryptionKey)
-	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
-	if p := unpad(plaintext); p != nil {
-		return string(p), nil
+	text, _, err := decryptTokenPadded(tok, hmac.New(sha256.New, signingKey), block, &opts)
+	return text, err
+}
+
+// DecryptTrimmed is like Decrypt but first removes any leading and
+// trailing white space from token, such as the newline that often comes
+// along when a token is copied and pasted. Only white space (as defined
+// by unicode.IsSpace) is removed; any other extraneous characters still
+// cause decryption to fail.
+func DecryptTrimmed(token, secret string, now time.Time, ttl time.Duration) (string, error) {
+	return Decrypt(strings.TrimSpace(token), secret, now, ttl)
+}
+
+// DecryptStrictBase64 is like Decrypt but rejects tokens that are not
+// canonically encoded. See DecryptOptions.StrictBase64.
+func DecryptStrictBase64(token, secret string, now time.Time, ttl time.Duration) (string, error) {
+	return DecryptWithOptions(token, secret, DecryptOptions{Now: now, TTL: ttl, StrictBase64: true})
+}
+
+// DecryptURLParam is like Decrypt but takes the token as it appears in
+// a URL query string, where its padding may be percent-encoded (as
+// "%3D"), and unescapes it first. An unescaped token is also accepted,
+// since unescaping does not change it.
+func DecryptURLParam(param, secret string, now time.Time, ttl time.Duration) (string, error) {
+	token, err := url.QueryUnescape(param)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	return Decrypt(token, secret, now, ttl)
+}
+
+// SamePlaintext reports whether tokenA and tokenB hold the same
+// message, which cannot be told by comparing the tokens, since their
+// IVs differ. Both tokens are fully verified, as by Decrypt, and the
+// messages are compared in constant time, though their lengths may
+// leak. If either token fails, the error is the one Decrypt would
+// return for it.
+func SamePlaintext(tokenA, tokenB, secret string, now time.Time, ttl time.Duration) (bool, error) {
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	a, _, err := decrypt(tokenA, secret, &opts)
+	if err != nil {
+		return false, err
+	}
+	b, _, err := decrypt(tokenB, secret, &opts)
+	if err != nil {
+		return false, err
 	}
-	return "", errors.New("fernet: invalid padding")
+	return subtle.ConstantTimeCompare(a, b) == 1, nil
 }
 
 // RandomSecret generates a secret suitable for use with Encrypt.
@@ -138,11 +666,22 @@ func RandomSecret() (string, error) {
 // Pads p using PKCS #7 standard block padding. (See
 // http://tools.ietf.org/html/rfc5652#section-6.3)
 func pad(q, p []byte) []byte {
-	const k = aes.BlockSize
 	copy(q, p)
-	n := paddedLen(len(p))
-	c := byte(n - len(p))
-	for i := len(p); i < n; i++ {
+	return padTail(q, len(p))
+}
+
+// Like pad but takes the message as a string. Converting the message to
+// a byte slice for pad may copy it an extra time.
+func padString(q []byte, p string) []byte {
+	copy(q, p)
+	return padTail(q, len(p))
+}
+
+// Pads q, which begins with a message of m bytes.
+func padTail(q []byte, m int) []byte {
+	n := paddedLen(m)
+	c := byte(n - m)
+	for i := m; i < n; i++ {
 		q[i] = c
 	}
 	return q[:n]
@@ -155,30 +694,57 @@ func paddedLen(n int) int {
 }
 
 // Reverses pad. Returns nil if any padding bytes are invalid.
+//
+// Since the HMAC is verified before anything is decrypted, an attacker
+// cannot use Decrypt as a padding oracle. Even so, as defense in depth,
+// unpad takes the same time regardless of the padding's value: it always
+// examines the entire final block and accumulates the result with
+// constant-time operations.
 func unpad(p []byte) []byte {
-	c := p[len(p)-1]
-	if int(c) > len(p) {
+	const k = aes.BlockSize
+	if len(p) == 0 || len(p)%k != 0 {
 		return nil
 	}
-	for i := len(p) - int(c); i < len(p); i++ {
-		if p[i] != c {
-			return nil
-		}
+	c := int(p[len(p)-1])
+	// The padding byte must be in [1, k]...
+	good := subtle.ConstantTimeLessOrEq(1, c) & subtle.ConstantTimeLessOrEq(c, k)
+	// ...and the last c bytes must all equal it.
+	for i := 1; i <= k; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, c)
+		good &= subtle.ConstantTimeByteEq(p[len(p)-i], byte(c)) | (inPadding ^ 1)
+	}
+	if good != 1 {
+		return nil
 	}
-	return p[:len(p)-int(c)]
+	return p[:len(p)-c]
 }
 
+// Errors returned for invalid secrets, possibly wrapped with more detail.
+var (
+	ErrSecretNotBase64   = errors.New("fernet: secret is not valid base64")
+	ErrSecretWrongLength = errors.New("fernet: secret must be 32 bytes")
+)
+
 // secret must be base64 encoded and 32 bytes long when decoded. Divides
 // it into two 16-byte blocks containing the signing and encrytion keys.
 func extractKeys(secret string) (signing, encryption []byte, err error) {
-	keys, err := base64.URLEncoding.DecodeString(secret)
+	keys, err := decodeSecret(base64.URLEncoding, secret)
 	if err != nil {
-		return nil, nil, fmt.Errorf("fernet: failed to decode secret: %v", err)
+		return nil, nil, err
+	}
+	return keys[:keyLen], keys[keyLen:], nil
+}
+
+// Decodes secret using enc and checks its length.
+func decodeSecret(enc *base64.Encoding, secret string) ([]byte, error) {
+	keys, err := enc.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSecretNotBase64, err)
 	}
 	if len(keys) != 2*keyLen {
-		return nil, nil, errors.New("fernet: secret must be 32 bytes")
+		return nil, ErrSecretWrongLength
 	}
-	return keys[:keyLen], keys[keyLen:], nil
+	return keys, nil
 }
 
 // Generates a random initialization vector and writes it to p.
@@ -1,7 +1,18 @@
 package fernet
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math"
+	"math/rand"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,7 +31,7 @@ func TestSpecGenerate(t *testing.T) {
 			return nil
 		}
 	)
-	tok, err := encrypt(msg, secret, now, ivfn)
+	tok, err := encrypt(msg, secret, &EncryptOptions{Now: now}, ivfn)
 	if err != nil {
 		t.Fatalf("encrypt error: %s", err)
 	}
@@ -192,3 +203,1020 @@ func TestDecrypt(t *testing.T) {
 		})
 	}
 }
+
+func TestNotBefore(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	var tests = []struct {
+		desc      string
+		notBefore time.Time
+		wantErr   error
+	}{
+		{"no cutoff", time.Time{}, nil},
+		{"cutoff before issuance", issued.Add(-time.Second), nil},
+		{"cutoff at issuance", issued, nil},
+		{"cutoff just after issuance", issued.Add(time.Nanosecond), ErrRevokedByCutoff},
+		{"cutoff after issuance", issued.Add(time.Second), ErrRevokedByCutoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			msg, err := DecryptWithOptions(tok, secret, DecryptOptions{
+				Now:       issued.Add(2 * time.Second),
+				TTL:       time.Minute,
+				NotBefore: tt.notBefore,
+			})
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && msg != "hello" {
+				t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+			}
+		})
+	}
+}
+
+func TestDecryptTrimmed(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	var tests = []struct {
+		desc  string
+		token string
+		ok    bool
+	}{
+		{"untouched", token, true},
+		{"trailing newline", token + "\n", true},
+		{"trailing CRLF", token + "\r\n", true},
+		{"leading and trailing spaces", "  " + token + "  ", true},
+		{"tabs", "\t" + token + "\t", true},
+		{"mixed", " \t\n" + token + "\n\t ", true},
+		{"trailing junk", token + ".", false},
+		{"quoted", `"` + token + `"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			msg, err := DecryptTrimmed(tt.token, secret, now, time.Minute)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if msg != "hello" {
+				t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+			}
+		})
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, msg string) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Encrypt(msg, secret, now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecrypt(b *testing.B, msg string) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := Encrypt(msg, secret, now)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decrypt(tok, secret, now, time.Minute); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B)      { benchmarkEncrypt(b, "hello, world") }
+func BenchmarkEncryptLarge(b *testing.B) { benchmarkEncrypt(b, strings.Repeat("x", 64<<10)) }
+func BenchmarkEncryptAligned(b *testing.B) {
+	benchmarkEncrypt(b, strings.Repeat("x", 4<<10))
+}
+func BenchmarkDecrypt(b *testing.B)      { benchmarkDecrypt(b, "hello, world") }
+func BenchmarkDecryptLarge(b *testing.B) { benchmarkDecrypt(b, strings.Repeat("x", 64<<10)) }
+
+func BenchmarkDecryptInvalid(b *testing.B) {
+	var (
+		token  = "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decrypt(token, secret, now, time.Minute); err == nil {
+			b.Fatal("expected an error")
+		}
+	}
+}
+
+// Garbage that fails the cheap pre-check in decodeToken is rejected
+// without decoding; garbage that passes it is fully decoded first.
+func BenchmarkDecryptGarbage(b *testing.B) {
+	benchmarkDecryptGarbage(b, strings.Repeat("x", 120))
+}
+
+func BenchmarkDecryptGarbageVersionPrefix(b *testing.B) {
+	benchmarkDecryptGarbage(b, "g"+strings.Repeat("x", 119))
+}
+
+func benchmarkDecryptGarbage(b *testing.B, token string) {
+	var (
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decrypt(token, secret, now, time.Minute); err == nil {
+			b.Fatal("expected an error")
+		}
+	}
+}
+
+func TestDecryptPrecheck(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	// A leading newline is ignored by the decoder, so it must not
+	// trip the pre-check.
+	for _, tok := range []string{token, "\n" + token, "\r\n" + token} {
+		if _, err := Decrypt(tok, secret, now, time.Minute); err != nil {
+			t.Fatalf("%q: decrypt error: %s", tok, err)
+		}
+	}
+	for _, tok := range []string{"", "g", "hello", "A" + token[1:], strings.Repeat("x", 120)} {
+		if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+			t.Fatalf("%q: expected an error", tok)
+		}
+	}
+}
+
+func TestDecryptMetadata(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		issued = time.Date(1985, time.October, 26, 8, 20, 0, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+
+	// A valid token.
+	msg, md, err := DecryptMetadata(token, secret, issued.Add(time.Second), time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+	}
+	if !md.Timestamp.Equal(issued) || md.Age != time.Second {
+		t.Fatalf("wrong metadata: %+v", md)
+	}
+
+	// An expired token.
+	msg, _, err = DecryptMetadata(token, secret, issued.Add(time.Hour), time.Minute)
+	if msg != "" {
+		t.Fatalf("expired token returned plaintext %q", msg)
+	}
+	if !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	var expErr *ExpiredError
+	if !errors.As(err, &expErr) {
+		t.Fatalf("got error of type %T, want *ExpiredError", err)
+	}
+	if md := expErr.Metadata; !md.Timestamp.Equal(issued) || md.Age != time.Hour {
+		t.Fatalf("wrong metadata: %+v", md)
+	}
+
+	// A forged token that would have expired is not reported as such.
+	forged := "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ=="
+	if _, _, err := DecryptMetadata(forged, secret, issued.Add(time.Hour), time.Minute); err == nil || errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want a signature error", err)
+	}
+}
+
+func TestMaxClockSkew(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	duration := func(d time.Duration) *time.Duration { return &d }
+	var tests = []struct {
+		desc string
+		now  time.Time
+		skew *time.Duration
+		ok   bool
+	}{
+		{"default, token from the past", issued.Add(time.Second), nil, true},
+		{"default, token from the near future", issued.Add(-time.Hour), nil, true},
+		{"default, token from the far future", issued.Add(-time.Hour - time.Nanosecond), nil, false},
+		{"zero, token from the past", issued.Add(time.Second), duration(0), true},
+		{"zero, token from now", issued, duration(0), true},
+		{"zero, token from the future", issued.Add(-time.Nanosecond), duration(0), false},
+		{"one second, token from the near future", issued.Add(-time.Second), duration(time.Second), true},
+		{"one second, token from the far future", issued.Add(-time.Second - time.Nanosecond), duration(time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := DecryptWithOptions(tok, secret, DecryptOptions{
+				Now:          tt.now,
+				TTL:          time.Minute,
+				MaxClockSkew: tt.skew,
+			})
+			if tt.ok && err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestExclusiveExpiry(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	var tests = []struct {
+		desc      string
+		now       time.Time
+		exclusive bool
+		ok        bool
+	}{
+		{"default, just before the TTL", issued.Add(time.Minute - time.Nanosecond), false, true},
+		{"default, at the TTL", issued.Add(time.Minute), false, true},
+		{"default, just after the TTL", issued.Add(time.Minute + time.Nanosecond), false, false},
+		{"exclusive, just before the TTL", issued.Add(time.Minute - time.Nanosecond), true, true},
+		{"exclusive, at the TTL", issued.Add(time.Minute), true, false},
+		{"exclusive, just after the TTL", issued.Add(time.Minute + time.Nanosecond), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := DecryptWithOptions(tok, secret, DecryptOptions{
+				Now:             tt.now,
+				TTL:             time.Minute,
+				ExclusiveExpiry: tt.exclusive,
+			})
+			if tt.ok && err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrExpired) {
+				t.Fatalf("got error %v, want %v", err, ErrExpired)
+			}
+		})
+	}
+}
+
+// A now with a monotonic clock reading, as returned by time.Now, gives
+// the same results as the same wall-clock time without one.
+func TestDecryptMonotonicNow(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	if now.String() == now.Round(0).String() {
+		t.Skip("time.Now has no monotonic clock reading on this platform")
+	}
+	tok, err := Encrypt("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	issued := time.Unix(now.Unix(), 0)
+	for _, d := range []time.Duration{0, time.Minute - time.Nanosecond, time.Minute, time.Minute + time.Nanosecond, time.Hour} {
+		// Adding to now keeps its monotonic reading.
+		mono := now.Add(issued.Add(d).Sub(now.Round(0)))
+		wall := mono.Round(0)
+		_, md1, err1 := DecryptMetadata(tok, secret, mono, time.Minute)
+		_, md2, err2 := DecryptMetadata(tok, secret, wall, time.Minute)
+		if (err1 == nil) != (err2 == nil) {
+			t.Fatalf("age %s: got error %v with a monotonic reading, %v without", d, err1, err2)
+		}
+		if md1.Age != d || md2.Age != d {
+			t.Fatalf("age %s: got ages %s and %s", d, md1.Age, md2.Age)
+		}
+		if ok := d <= time.Minute; ok != (err1 == nil) {
+			t.Fatalf("age %s: got error %v", d, err1)
+		}
+	}
+}
+
+func TestEncryptWithOptions(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, 10, 26, 8, 20, 0, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		iv     = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	)
+	// Custom Now and Rand reproduce the spec's test vector.
+	tok, err := EncryptWithOptions("hello", secret, EncryptOptions{
+		Now:  now,
+		Rand: bytes.NewReader(iv),
+	})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if tok != token {
+		t.Fatalf("wrong token: got %q, want %q", tok, token)
+	}
+
+	// A failing Rand causes an error.
+	if _, err := EncryptWithOptions("hello", secret, EncryptOptions{
+		Now:  now,
+		Rand: bytes.NewReader(iv[:8]),
+	}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// A custom version is not accepted by Decrypt.
+	tok, err = EncryptWithOptions("hello", secret, EncryptOptions{Now: now, Version: 0x81})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if tok[:2] == token[:2] {
+		t.Fatalf("token %q does not have a custom version", tok)
+	}
+	if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestAcceptedVersions(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	opts := DecryptOptions{Now: now, TTL: time.Minute, AcceptedVersions: []byte{0x80, 0x81}}
+	for _, v := range []byte{0x80, 0x81} {
+		tok, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now, Version: v})
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		msg, err := DecryptWithOptions(tok, secret, opts)
+		if err != nil {
+			t.Fatalf("version %#x: decrypt error: %s", v, err)
+		}
+		if msg != "hello" {
+			t.Fatalf("version %#x: wrong message: got %q, want %q", v, msg, "hello")
+		}
+	}
+	tok, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now, Version: 0x82})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptWithOptions(tok, secret, opts); err == nil {
+		t.Fatal("accepted an unlisted version")
+	}
+	// The list replaces the default rather than adding to it.
+	tok, err = Encrypt("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	opts.AcceptedVersions = []byte{0x81}
+	if _, err := DecryptWithOptions(tok, secret, opts); err == nil {
+		t.Fatal("accepted a standard token")
+	}
+}
+
+// The options cannot be used to confuse an extension's tokens with
+// standard ones.
+func TestReservedVersions(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	timeless, err := EncryptTimeless("hello", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v := versionReservedMin; v <= versionReservedMax; v++ {
+		if _, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now, Version: byte(v)}); err != ErrReservedVersion {
+			t.Errorf("encrypt with version %#x: got error %v, want %v", v, err, ErrReservedVersion)
+		}
+		opts := DecryptOptions{Now: now, TTL: NoTTL, AcceptedVersions: []byte{0x80, byte(v)}}
+		if _, err := DecryptWithOptions(timeless, secret, opts); err != ErrReservedVersion {
+			t.Errorf("decrypt accepting version %#x: got error %v, want %v", v, err, ErrReservedVersion)
+		}
+	}
+	// Every extension's version is reserved.
+	for p := range profileNames {
+		if p != ProfileStandard && !isReservedVersion(byte(p)) {
+			t.Errorf("profile %s has unreserved version %#x", p, byte(p))
+		}
+	}
+}
+
+func TestDecryptStrictBase64(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	if _, err := DecryptStrictBase64(token, secret, now, time.Minute); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	// Each of these decodes to the same bytes as token.
+	var tests = []struct {
+		desc  string
+		token string
+	}{
+		{"nonzero trailing bits", token[:len(token)-3] + "B=="},
+		{"all trailing bits set", token[:len(token)-3] + "P=="},
+		{"embedded newline", token[:20] + "\n" + token[20:]},
+		{"embedded carriage return", token[:20] + "\r" + token[20:]},
+		{"trailing newline", token + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			// Make sure the lenient decoder accepts the variant.
+			if _, err := Decrypt(tt.token, secret, now, time.Minute); err != nil {
+				t.Fatalf("Decrypt rejected the token: %s", err)
+			}
+			if _, err := DecryptStrictBase64(tt.token, secret, now, time.Minute); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecryptURLParam(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	escaped := url.QueryEscape(token)
+	if escaped == token {
+		t.Fatal("QueryEscape did not escape the token")
+	}
+	for _, param := range []string{
+		token,
+		escaped,
+		strings.Replace(escaped, "%3D", "%3d", -1),
+	} {
+		msg, err := DecryptURLParam(param, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%q: decrypt error: %s", param, err)
+		}
+		if msg != "hello" {
+			t.Fatalf("%q: wrong message: got %q, want %q", param, msg, "hello")
+		}
+	}
+	// Decrypt does not unescape.
+	if _, err := Decrypt(escaped, secret, now, time.Minute); err == nil {
+		t.Fatal("Decrypt accepted a percent-encoded token")
+	}
+	if _, err := DecryptURLParam(token[:10]+"%zz", secret, now, time.Minute); !errors.Is(err, ErrTokenNotBase64) {
+		t.Fatalf("got error %v, want %v", err, ErrTokenNotBase64)
+	}
+}
+
+// encodeToken's fast path must not change the output.
+func TestEncodeToken(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 73, streamEncodeThreshold - 1, streamEncodeThreshold, streamEncodeThreshold + 1, streamEncodeThreshold + 2, 64 << 10} {
+		tok := make([]byte, n)
+		rng.Read(tok)
+		if got, want := encodeToken(tok), base64.URLEncoding.EncodeToString(tok); got != want {
+			t.Fatalf("%d bytes: encodeToken does not match EncodeToString", n)
+		}
+	}
+}
+
+func TestUnpad(t *testing.T) {
+	block := func(tail ...byte) []byte {
+		p := bytes.Repeat([]byte{'x'}, 16-len(tail))
+		return append(p, tail...)
+	}
+	var tests = []struct {
+		desc string
+		p    []byte
+		want []byte // nil if invalid
+	}{
+		{"one byte", block(1), block()[:15]},
+		{"two bytes", block(2, 2), block()[:14]},
+		{"full block", bytes.Repeat([]byte{16}, 16), []byte{}},
+		{"two blocks", append(block(), block(3, 3, 3)...), append(block(), block()[:13]...)},
+		{"zero", block(0), nil},
+		{"too large", block(17), nil},
+		{"way too large", block(0xff), nil},
+		{"mismatch", block(1, 3, 3), nil},
+		{"mismatch at start", block(2, 3, 3), nil},
+		{"empty", []byte{}, nil},
+		{"partial block", []byte{1}, nil},
+		{"larger than block in multi-block input", append(bytes.Repeat([]byte{17}, 16), bytes.Repeat([]byte{17}, 16)...), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := unpad(tt.p)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("got %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || !bytes.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// unpad must reverse pad for every length.
+	for n := 0; n < 50; n++ {
+		msg := bytes.Repeat([]byte{'a'}, n)
+		if got := unpad(pad(make([]byte, paddedLen(n)), msg)); !bytes.Equal(got, msg) {
+			t.Fatalf("unpad(pad(%d bytes)) = %v", n, got)
+		}
+	}
+}
+
+// An empty message is a valid, distinct success, not an error.
+func TestDecryptEmpty(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := Encrypt("", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	msg, err := Decrypt(tok, secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	if msg != "" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "")
+	}
+	msg, md, err := DecryptMetadata(tok, secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	if msg != "" || md.Timestamp.IsZero() {
+		t.Fatalf("got (%q, %+v), want an empty message with metadata", msg, md)
+	}
+}
+
+func TestIsExpiredAuthentic(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		issued = time.Date(1985, time.October, 26, 8, 20, 0, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	var tests = []struct {
+		desc  string
+		token string
+		now   time.Time
+		want  bool
+		err   bool
+	}{
+		{"valid", token, issued.Add(time.Second), false, false},
+		{"at TTL", token, issued.Add(time.Minute), false, false},
+		{"expired", token, issued.Add(time.Minute + time.Second), true, false},
+		{"long expired", token, issued.Add(24 * 365 * time.Hour), true, false},
+		{"forged", "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ==", issued.Add(time.Hour), false, true},
+		{"bad padding", "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0ODz4LEpdELGQAad7aNEHbf-JkLPIpuiYRLQ3RtXatOYREu2FWke6CnJNYIbkuKNqOhw==", issued.Add(time.Hour), false, true},
+		{"from the future", token, issued.Add(-2 * time.Hour), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := IsExpiredAuthentic(tt.token, secret, tt.now, time.Minute)
+			if tt.err != (err != nil) {
+				t.Fatalf("got error %v, want error: %t", err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// A cipher.Block that records how many blocks it has decrypted.
+type recordingBlock struct {
+	cipher.Block
+	decrypted int
+}
+
+func (b *recordingBlock) Decrypt(dst, src []byte) {
+	b.decrypted++
+	b.Block.Decrypt(dst, src)
+}
+
+// Regression test: a token whose HMAC is wrong must be rejected before
+// any of it is decrypted, and no plaintext may be returned.
+func TestNoDecryptionOnMACFailure(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesBlock, _ := aes.NewCipher(encryptionKey)
+	decryptWith := func(tok []byte) ([]byte, int, error) {
+		block := &recordingBlock{Block: aesBlock}
+		msg, _, err := decryptToken(tok, hmac.New(sha256.New, signingKey), block, &DecryptOptions{Now: now, TTL: time.Minute})
+		return msg, block.decrypted, err
+	}
+
+	// Sanity check: a valid token is decrypted via the recording block.
+	tok, err := decodeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, n, err := decryptWith(tok); err != nil || string(msg) != "hello" || n == 0 {
+		t.Fatalf("got (%q, %d, %v), want (%q, >0, nil)", msg, n, err, "hello")
+	}
+
+	// Flip each bit of the token in turn.
+	orig, _ := decodeToken(token)
+	for i := 0; i < len(orig)*8; i++ {
+		tok := append([]byte(nil), orig...)
+		tok[i/8] ^= 1 << uint(i%8)
+		msg, n, err := decryptWith(tok)
+		if err == nil {
+			t.Fatalf("bit %d: tampered token accepted", i)
+		}
+		if msg != nil || n != 0 {
+			t.Fatalf("bit %d: got %q after %d block decryptions, want none", i, msg, n)
+		}
+	}
+
+	// The public functions return no plaintext either.
+	bad := token[:len(token)-4] + "AA=="
+	if msg, err := Decrypt(bad, secret, now, time.Minute); err == nil || msg != "" {
+		t.Fatalf("Decrypt: got (%q, %v), want an error and no message", msg, err)
+	}
+	if msg, _, err := DecryptMetadata(bad, secret, now, time.Minute); err == nil || msg != "" {
+		t.Fatalf("DecryptMetadata: got (%q, %v), want an error and no message", msg, err)
+	}
+	if r, err := NewTokenReader(bad, secret, now, time.Minute); err == nil || r != nil {
+		t.Fatalf("NewTokenReader: got (%v, %v), want an error and no reader", r, err)
+	}
+}
+
+func TestDecryptNoUnpad(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, msg := range []string{"", "hello", "0123456789abcdef", "a longer message spanning several blocks"} {
+		tok, err := Encrypt(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		padded, err := DecryptNoUnpad(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		unpadded, err := Decrypt(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		n := 16 - len(msg)%16
+		want := msg + strings.Repeat(string(rune(n)), n)
+		if string(padded) != want || !strings.HasPrefix(want, unpadded) {
+			t.Fatalf("got %q, want %q", padded, want)
+		}
+	}
+	// The token is still verified.
+	tok, _ := Encrypt("hello", secret, now)
+	if _, err := DecryptNoUnpad(tok, secret, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	bad := tok[:len(tok)-4] + "AA=="
+	if p, err := DecryptNoUnpad(bad, secret, now, time.Minute); err == nil || p != nil {
+		t.Fatalf("got (%q, %v), want an error and no plaintext", p, err)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	msg := strings.Repeat("x", 100)
+	if _, err := EncryptWithOptions(msg, secret, EncryptOptions{Now: now, MaxMessageSize: 100}); err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := EncryptWithOptions(msg, secret, EncryptOptions{Now: now, MaxMessageSize: 99}); err != ErrMessageTooLarge {
+		t.Fatalf("got error %v, want %v", err, ErrMessageTooLarge)
+	}
+	if _, err := EncryptWithOptions(msg, secret, EncryptOptions{Now: now, MaxMessageSize: -1}); err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+
+	// Lengths whose token length would overflow are always rejected,
+	// even without a limit.
+	const maxInt = int(^uint(0) >> 1)
+	tests := []struct {
+		n, limit int
+		ok       bool
+	}{
+		{DefaultMaxMessageSize, 0, true},
+		{DefaultMaxMessageSize + 1, 0, false},
+		{DefaultMaxMessageSize + 1, -1, true},
+		{maxInt, 0, false},
+		{maxInt, -1, false},
+		{maxInt, maxInt, false},
+		{maxInt - fixedLen - 15, -1, false},
+	}
+	for _, tt := range tests {
+		err := checkMessageLen(tt.n, tt.limit)
+		if tt.ok && err != nil || !tt.ok && err != ErrMessageTooLarge {
+			t.Errorf("checkMessageLen(%d, %d) = %v", tt.n, tt.limit, err)
+		}
+	}
+}
+
+// The extensions and the multi-secret functions share the default limit.
+func TestMaxMessageSizeOtherFormats(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	msg := strings.Repeat("x", DefaultMaxMessageSize+1)
+	if _, err := EncryptTimeless(msg, secret); err != ErrMessageTooLarge {
+		t.Errorf("EncryptTimeless: got error %v, want %v", err, ErrMessageTooLarge)
+	}
+	if _, err := EncryptForSecrets(msg, []string{secret}, now); err != ErrMessageTooLarge {
+		t.Errorf("EncryptForSecrets: got error %v, want %v", err, ErrMessageTooLarge)
+	}
+	if _, err := EncryptFanout(msg, map[string]string{"a": secret}, now); err != ErrMessageTooLarge {
+		t.Errorf("EncryptFanout: got error %v, want %v", err, ErrMessageTooLarge)
+	}
+}
+
+func TestWarnOnWeakIV(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	weak := [][]byte{
+		make([]byte, 16),
+		bytes.Repeat([]byte{0xff}, 16),
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		{250, 251, 252, 253, 254, 255, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	for _, iv := range weak {
+		opts := EncryptOptions{Now: now, Rand: bytes.NewReader(iv), WarnOnWeakIV: true}
+		if _, err := EncryptWithOptions("hello", secret, opts); err != ErrWeakIV {
+			t.Errorf("IV %v: got error %v, want %v", iv, err, ErrWeakIV)
+		}
+		// The check is off by default.
+		opts = EncryptOptions{Now: now, Rand: bytes.NewReader(iv)}
+		if _, err := EncryptWithOptions("hello", secret, opts); err != nil {
+			t.Errorf("IV %v: encrypt error: %s", iv, err)
+		}
+	}
+	iv := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 16}
+	opts := EncryptOptions{Now: now, Rand: bytes.NewReader(iv), WarnOnWeakIV: true}
+	if _, err := EncryptWithOptions("hello", secret, opts); err != nil {
+		t.Errorf("IV %v: encrypt error: %s", iv, err)
+	}
+	if _, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now, WarnOnWeakIV: true}); err != nil {
+		t.Errorf("encrypt error with a random IV: %s", err)
+	}
+}
+
+func TestDecryptUntil(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := issued.Add(30 * 24 * time.Hour)
+	for _, tt := range []struct {
+		now  time.Time
+		want error
+	}{
+		{issued, nil},
+		{expiry.Add(-time.Nanosecond), nil},
+		{expiry, nil},
+		{expiry.Add(time.Nanosecond), ErrExpired},
+		{expiry.Add(time.Hour), ErrExpired},
+	} {
+		msg, err := DecryptUntil(tok, secret, tt.now, expiry)
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("now %s: got error %v, want %v", tt.now, err, tt.want)
+		}
+		if err == nil && msg != "hello" {
+			t.Fatalf("now %s: got %q, want %q", tt.now, msg, "hello")
+		}
+	}
+	if _, err := DecryptUntil(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", issued, expiry); !errors.Is(err, ErrWrongHMAC) {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+
+	// With both a TTL and an expiry time, whichever comes first applies.
+	for _, tt := range []struct {
+		ttl       time.Duration
+		expiry    time.Time
+		exclusive bool
+		now       time.Time
+		want      error
+	}{
+		{time.Hour, expiry, false, issued.Add(time.Hour + time.Second), ErrExpired},
+		{time.Hour, issued.Add(time.Minute), false, issued.Add(2 * time.Minute), ErrExpired},
+		{time.Hour, issued.Add(time.Minute), false, issued.Add(time.Minute), nil},
+		{time.Hour, issued.Add(time.Minute), true, issued.Add(time.Minute), ErrExpired},
+		{time.Hour, time.Time{}, false, issued.Add(time.Minute), nil},
+	} {
+		opts := DecryptOptions{Now: tt.now, TTL: tt.ttl, Expiry: tt.expiry, ExclusiveExpiry: tt.exclusive}
+		if _, err := DecryptWithOptions(tok, secret, opts); !errors.Is(err, tt.want) {
+			t.Fatalf("%+v: got error %v, want %v", opts, err, tt.want)
+		}
+	}
+}
+
+func TestDecryptMinTTL(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		ttl, min time.Duration
+		want     error
+	}{
+		{0, 0, nil}, // the guard is off by default
+		{0, time.Second, ErrTTLTooShort},
+		{time.Millisecond, time.Second, ErrTTLTooShort},
+		{time.Second - 1, time.Second, ErrTTLTooShort},
+		{time.Second, time.Second, nil},
+		{time.Hour, time.Second, nil},
+		{NoTTL, time.Hour, nil},
+	} {
+		opts := DecryptOptions{Now: now, TTL: tt.ttl, MinTTL: tt.min}
+		if _, err := DecryptWithOptions(tok, secret, opts); err != tt.want {
+			t.Errorf("TTL %s, minimum %s: got error %v, want %v", tt.ttl, tt.min, err, tt.want)
+		}
+	}
+	// NoTTL disables expiry.
+	if _, err := Decrypt(tok, secret, now.AddDate(100, 0, 0), NoTTL); err != nil {
+		t.Fatalf("got error %v with NoTTL", err)
+	}
+}
+
+func TestDecryptMinIssued(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := issued.Add(time.Minute)
+	for _, tt := range []struct {
+		minIssued time.Time
+		want      error
+	}{
+		{time.Time{}, nil},
+		{issued.Add(-time.Second), nil},
+		{issued, nil},
+		{issued.Add(time.Nanosecond), ErrTooOld},
+		{issued.Add(time.Second), ErrTooOld},
+	} {
+		msg, err := DecryptMinIssued(tok, secret, now, tt.minIssued, time.Hour)
+		if err != tt.want {
+			t.Fatalf("minimum %s: got error %v, want %v", tt.minIssued, err, tt.want)
+		}
+		if err == nil && msg != "hello" {
+			t.Fatalf("minimum %s: got %q, want %q", tt.minIssued, msg, "hello")
+		}
+	}
+	// The TTL and signature are still checked.
+	if _, err := DecryptMinIssued(tok, secret, now, issued, time.Second); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := DecryptMinIssued(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now, issued, time.Hour); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+}
+
+func TestRejectKnownWeakSecrets(t *testing.T) {
+	const (
+		specSecret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		goodSecret = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+	)
+	now := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	for _, secret := range []string{
+		specSecret,
+		"cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e5=", // the same key, non-canonically encoded
+		"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	} {
+		if _, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now, RejectKnownWeakSecrets: true}); err != ErrKnownWeakSecret {
+			t.Errorf("encrypt with %q: got error %v, want %v", secret, err, ErrKnownWeakSecret)
+		}
+		// Off by default.
+		tok, err := EncryptWithOptions("hello", secret, EncryptOptions{Now: now})
+		if err != nil {
+			t.Fatalf("encrypt with %q: %s", secret, err)
+		}
+		if _, err := DecryptWithOptions(tok, secret, DecryptOptions{Now: now, TTL: time.Minute}); err != nil {
+			t.Fatalf("decrypt with %q: %s", secret, err)
+		}
+		if _, err := DecryptWithOptions(tok, secret, DecryptOptions{Now: now, TTL: time.Minute, RejectKnownWeakSecrets: true}); err != ErrKnownWeakSecret {
+			t.Errorf("decrypt with %q: got error %v, want %v", secret, err, ErrKnownWeakSecret)
+		}
+	}
+	tok, err := EncryptWithOptions("hello", goodSecret, EncryptOptions{Now: now, RejectKnownWeakSecrets: true})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptWithOptions(tok, goodSecret, DecryptOptions{Now: now, TTL: time.Minute, RejectKnownWeakSecrets: true}); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+}
+
+func TestMaxSafeTTL(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	if got := MaxSafeTTL(); got != math.MaxInt64-1 {
+		t.Fatalf("got %d, want %d", got, int64(math.MaxInt64-1))
+	}
+	// Tokens whose ages saturate the Duration type, in both directions.
+	epoch := time.Unix(0, 0)
+	farFuture := time.Date(2400, 1, 1, 0, 0, 0, 0, time.UTC)
+	farPast := time.Date(-1000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		issued, now time.Time
+		ttl         time.Duration
+		exclusive   bool
+		want        error
+	}{
+		{epoch, farFuture, MaxSafeTTL(), false, ErrExpired},
+		{farPast, epoch, MaxSafeTTL(), false, ErrExpired},
+		{farPast, farFuture, MaxSafeTTL(), false, ErrExpired},
+		{farPast, farFuture, MaxSafeTTL(), true, ErrExpired},
+		{epoch, farFuture, NoTTL, false, nil},
+		{farPast, farFuture, NoTTL, false, nil},
+		{farPast, farFuture, NoTTL, true, nil},
+		{farPast, farFuture, math.MaxInt64, true, nil},
+		{epoch, epoch.Add(time.Hour), MaxSafeTTL(), true, nil},
+		{farFuture, epoch, NoTTL, false, ErrClockSkew},
+	} {
+		tok, err := Encrypt("hello", secret, tt.issued)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := DecryptOptions{Now: tt.now, TTL: tt.ttl, ExclusiveExpiry: tt.exclusive}
+		if _, err := DecryptWithOptions(tok, secret, opts); !errors.Is(err, tt.want) {
+			t.Errorf("issued %s, now %s, TTL %d, exclusive %t: got error %v, want %v",
+				tt.issued, tt.now, tt.ttl, tt.exclusive, err, tt.want)
+		}
+	}
+}
+
+func TestSamePlaintext(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	encrypt := func(msg string, issued time.Time) string {
+		t.Helper()
+		tok, err := Encrypt(msg, secret, issued)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	hello := encrypt("hello", now)
+	for _, tt := range []struct {
+		desc  string
+		other string
+		want  bool
+	}{
+		{"same message", encrypt("hello", now), true},
+		{"same message, different time", encrypt("hello", now.Add(-time.Second)), true},
+		{"same token", hello, true},
+		{"different message", encrypt("hellp", now), false},
+		{"prefix", encrypt("hell", now), false},
+		{"empty", encrypt("", now), false},
+	} {
+		got, err := SamePlaintext(hello, tt.other, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.desc, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %t, want %t", tt.desc, got, tt.want)
+		}
+	}
+	expired := encrypt("hello", now.Add(-time.Hour))
+	if _, err := SamePlaintext(hello, expired, secret, now, time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	other, err := Encrypt("hello", "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SamePlaintext(other, hello, secret, now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+}
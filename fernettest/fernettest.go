@@ -0,0 +1,111 @@
+// Package fernettest provides utilities for testing code that uses
+// package fernet.
+package fernettest
+
+import (
+	"time"
+
+	"github.com/dcowgill/fernet"
+)
+
+// ExpiredToken returns a token whose timestamp is age before the current
+// time, so that it is rejected by Decrypt with any TTL less than age.
+func ExpiredToken(msg, secret string, age time.Duration) (string, error) {
+	return fernet.Encrypt(msg, secret, time.Now().Add(-age))
+}
+
+// FutureToken returns a token whose timestamp is ahead of the current
+// time by the given amount, for testing clock skew handling.
+func FutureToken(msg, secret string, ahead time.Duration) (string, error) {
+	return fernet.Encrypt(msg, secret, time.Now().Add(ahead))
+}
+
+// InvalidCase is a token from the Fernet spec's catalog of invalid
+// tokens, along with the error that Decrypt returns for it.
+type InvalidCase struct {
+	Desc      string
+	Token     string
+	Now       time.Time
+	TTL       time.Duration
+	Secret    string
+	WantError error // matches Decrypt's error according to errors.Is
+}
+
+// InvalidCases returns the invalid tokens from the Fernet spec (see
+// https://github.com/fernet/spec/blob/master/invalid.json). Other
+// implementations can use them to check that each token is rejected for
+// the right reason, not merely rejected.
+func InvalidCases() []InvalidCase {
+	var (
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	return []InvalidCase{
+		{
+			Desc:      "incorrect mac",
+			Token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrWrongHMAC,
+		},
+		{
+			Desc:      "too short",
+			Token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrTokenTooShort,
+		},
+		{
+			Desc:      "invalid base64",
+			Token:     "%%%%%%%%%%%%%AECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykRtfsH-p1YsUD2Q==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrTokenNotBase64,
+		},
+		{
+			Desc:   "payload size not multiple of block size",
+			Token:  "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPOm73QeoCk9uGib28Xe5vz6oxq5nmxbx_v7mrfyudzUm",
+			Now:    now,
+			TTL:    time.Minute,
+			Secret: secret,
+			// The token decodes to fewer bytes than the shortest
+			// valid token, which is checked first.
+			WantError: fernet.ErrTokenTooShort,
+		},
+		{
+			Desc:      "payload padding error",
+			Token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0ODz4LEpdELGQAad7aNEHbf-JkLPIpuiYRLQ3RtXatOYREu2FWke6CnJNYIbkuKNqOhw==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrInvalidPadding,
+		},
+		{
+			Desc:      "far-future TS (unacceptable clock skew)",
+			Token:     "gAAAAAAdwStRAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAnja1xKYyhd-Y6mSkTOyTGJmw2Xc2a6kBd-iX9b_qXQcw==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrClockSkew,
+		},
+		{
+			Desc:      "expired TTL",
+			Token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykRtfsH-p1YsUD2Q==",
+			Now:       time.Date(1985, time.October, 26, 8, 21, 31, 0, time.UTC),
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrExpired,
+		},
+		{
+			Desc:      "incorrect IV (causes padding error)",
+			Token:     "gAAAAAAdwJ6xBQECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAkLhFLHpGtDBRLRTZeUfWgHSv49TF2AUEZ1TIvcZjK1zQ==",
+			Now:       now,
+			TTL:       time.Minute,
+			Secret:    secret,
+			WantError: fernet.ErrInvalidPadding,
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package fernettest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dcowgill/fernet"
+)
+
+const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+
+func TestExpiredToken(t *testing.T) {
+	tok, err := ExpiredToken("hello", secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fernet.Decrypt(tok, secret, time.Now(), time.Minute); !errors.Is(err, fernet.ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, fernet.ErrExpired)
+	}
+	if msg, err := fernet.Decrypt(tok, secret, time.Now(), 2*time.Hour); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+}
+
+func TestFutureToken(t *testing.T) {
+	tok, err := FutureToken("hello", secret, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fernet.Decrypt(tok, secret, time.Now(), time.Minute); err == nil {
+		t.Fatal("expected a clock skew error")
+	}
+	tok, err = FutureToken("hello", secret, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := fernet.Decrypt(tok, secret, time.Now(), time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+}
+
+func TestInvalidCases(t *testing.T) {
+	cases := InvalidCases()
+	if len(cases) == 0 {
+		t.Fatal("no cases")
+	}
+	for _, c := range cases {
+		t.Run(c.Desc, func(t *testing.T) {
+			msg, err := fernet.Decrypt(c.Token, c.Secret, c.Now, c.TTL)
+			if !errors.Is(err, c.WantError) {
+				t.Fatalf("got error %v, want %v", err, c.WantError)
+			}
+			if msg != "" {
+				t.Fatalf("got message %q for an invalid token", msg)
+			}
+		})
+	}
+}
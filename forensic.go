@@ -0,0 +1,62 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ForensicResult reports the outcome of each check DecryptForensic
+// performs on a token.
+type ForensicResult struct {
+	VersionOK bool      // the version byte is correct
+	LengthOK  bool      // the token is long enough and its ciphertext is a whole number of blocks
+	MACOK     bool      // the HMAC is correct
+	PaddingOK bool      // the decrypted message is correctly padded
+	Timestamp time.Time // the unauthenticated timestamp; zero if the token is too short
+	Plaintext []byte    // the decrypted message if PaddingOK; otherwise nil
+}
+
+// DecryptForensic examines token for an offline diagnostic tool. Unlike
+// Decrypt, which stops at the first problem, it runs every check it can
+// and reports the results, and it ignores the token's age. It returns an
+// error only if token is not valid base64 or secret is invalid.
+//
+// DecryptForensic decrypts the token even if its HMAC is wrong, so its
+// results must never be used to make decisions about authentication.
+func DecryptForensic(token, secret string) (ForensicResult, error) {
+	var r ForensicResult
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return r, fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return r, err
+	}
+	n := len(tok)
+	r.VersionOK = n > 0 && tok[0] == version
+	r.LengthOK = n >= fixedLen+aes.BlockSize && (n-fixedLen)%aes.BlockSize == 0
+	if n >= tsOffset+tsLen {
+		r.Timestamp = time.Unix(int64(binary.BigEndian.Uint64(tok[tsOffset:])), 0)
+	}
+	if n >= fixedLen {
+		macOffset := n - sha256.Size
+		hash := hmac.New(sha256.New, signingKey)
+		_, _ = hash.Write(tok[:macOffset])
+		r.MACOK = hmac.Equal(tok[macOffset:], hash.Sum(nil))
+	}
+	if r.LengthOK {
+		ciphertext := tok[msgOffset : n-sha256.Size]
+		block, _ := aes.NewCipher(encryptionKey)
+		cipher.NewCBCDecrypter(block, tok[ivOffset:msgOffset]).CryptBlocks(ciphertext, ciphertext)
+		r.Plaintext = unpad(ciphertext)
+		r.PaddingOK = r.Plaintext != nil
+	}
+	return r, nil
+}
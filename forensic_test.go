@@ -0,0 +1,74 @@
+package fernet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecryptForensic(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	ts := time.Date(1985, time.October, 26, 8, 20, 1, 0, time.UTC)
+	var tests = []struct {
+		desc                                  string
+		token                                 string
+		versionOK, lengthOK, macOK, paddingOK bool
+		plaintext                             string
+	}{
+		{
+			desc:      "valid",
+			token:     "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA==",
+			versionOK: true, lengthOK: true, macOK: true, paddingOK: true,
+			plaintext: "hello",
+		},
+		{
+			desc:      "incorrect mac",
+			token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ==",
+			versionOK: true, lengthOK: true, macOK: false, paddingOK: true,
+		},
+		{
+			desc:      "too short",
+			token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA==",
+			versionOK: true,
+		},
+		{
+			desc:      "payload size not multiple of block size",
+			token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPOm73QeoCk9uGib28Xe5vz6oxq5nmxbx_v7mrfyudzUm",
+			versionOK: true, macOK: true,
+		},
+		{
+			desc:      "payload padding error",
+			token:     "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0ODz4LEpdELGQAad7aNEHbf-JkLPIpuiYRLQ3RtXatOYREu2FWke6CnJNYIbkuKNqOhw==",
+			versionOK: true, lengthOK: true, macOK: true,
+		},
+		{
+			desc:      "wrong version",
+			token:     "gQAAAAAdwJ6wQ8GRfv-iibwY6qBXVaO8ZU9TcMHFA_XDv5UI3hEfpPJBMfoKof-xgauZjeaed2JbQNyzuGZdkduHXGsQX0NS7Q==",
+			versionOK: false, lengthOK: true, macOK: true, paddingOK: true,
+			plaintext: "hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r, err := DecryptForensic(tt.token, secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.VersionOK != tt.versionOK || r.LengthOK != tt.lengthOK || r.MACOK != tt.macOK || r.PaddingOK != tt.paddingOK {
+				t.Fatalf("got %+v, want version %t, length %t, MAC %t, padding %t",
+					r, tt.versionOK, tt.lengthOK, tt.macOK, tt.paddingOK)
+			}
+			if string(r.Plaintext) != tt.plaintext {
+				t.Fatalf("wrong plaintext: got %q, want %q", r.Plaintext, tt.plaintext)
+			}
+			if r.Timestamp.Sub(ts) > time.Second || ts.Sub(r.Timestamp) > time.Second {
+				t.Fatalf("wrong timestamp: %s", r.Timestamp)
+			}
+		})
+	}
+	if _, err := DecryptForensic("%%%", secret); err == nil {
+		t.Fatal("expected an error for invalid base64")
+	}
+	if _, err := DecryptForensic("gAAA", "bogus"); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
@@ -0,0 +1,181 @@
+package fernet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// A Framer separates the tokens in a stream of tokens, so that streams
+// can be exchanged with systems that expect a particular framing.
+type Framer interface {
+	// WriteFrame writes token to w as a single frame.
+	WriteFrame(w io.Writer, token []byte) error
+
+	// ReadFrame reads the next frame from r and returns the token it
+	// holds. It returns io.EOF if r is at the end of the stream, and
+	// io.ErrUnexpectedEOF if the stream ends part way through a frame.
+	ReadFrame(r io.Reader) ([]byte, error)
+}
+
+// LengthPrefixFramer frames each token with its length, as a 32-bit
+// big-endian integer.
+type LengthPrefixFramer struct{}
+
+// Limits the memory ReadFrame allocates based on an unauthenticated
+// length.
+var maxFrameLen = MaxTokenLen(DefaultMaxMessageSize)
+
+// WriteFrame implements Framer.
+func (LengthPrefixFramer) WriteFrame(w io.Writer, token []byte) error {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(token)))
+	if _, err := w.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(token)
+	return err
+}
+
+// ReadFrame implements Framer.
+func (LengthPrefixFramer) ReadFrame(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if uint64(size) > uint64(maxFrameLen) {
+		return nil, errors.New("fernet: frame is too long")
+	}
+	token := make([]byte, size)
+	if _, err := io.ReadFull(r, token); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// NewlineFramer writes each token on its own line. Since tokens never
+// contain newlines, no escaping is needed. ReadFrame also accepts lines
+// ending in "\r\n" and a last line with no newline.
+type NewlineFramer struct{}
+
+// WriteFrame implements Framer.
+func (NewlineFramer) WriteFrame(w io.Writer, token []byte) error {
+	line := make([]byte, len(token)+1)
+	copy(line, token)
+	line[len(token)] = '\n'
+	_, err := w.Write(line)
+	return err
+}
+
+// ReadFrame implements Framer. If r does not implement io.ByteReader,
+// it is read one byte at a time, so that nothing past the newline is
+// consumed.
+func (NewlineFramer) ReadFrame(r io.Reader) ([]byte, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = oneByteReader{r}
+	}
+	var line []byte
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == '\n' {
+			break
+		}
+		if len(line) >= maxFrameLen {
+			return nil, errors.New("fernet: frame is too long")
+		}
+		line = append(line, c)
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// Adapts an io.Reader to an io.ByteReader.
+type oneByteReader struct{ r io.Reader }
+
+func (o oneByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(o.r, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// TokenStreamWriter encrypts each message as a separate token and
+// writes the tokens to an underlying writer, framed by a Framer. Unlike
+// the chunked stream format, the tokens are independent: the stream
+// can be read by any Fernet implementation that understands the
+// framing, but nothing prevents tokens from being dropped or reordered.
+type TokenStreamWriter struct {
+	w      io.Writer
+	key    *Key
+	framer Framer
+}
+
+// NewTokenStreamWriter returns a TokenStreamWriter that writes tokens
+// encrypted with secret to w, framed by f, or by NewlineFramer if f is
+// nil.
+func NewTokenStreamWriter(w io.Writer, secret string, f Framer) (*TokenStreamWriter, error) {
+	k, err := NewKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		f = NewlineFramer{}
+	}
+	return &TokenStreamWriter{w: w, key: k, framer: f}, nil
+}
+
+// WriteMessage encrypts msg and writes the token as the next frame.
+func (sw *TokenStreamWriter) WriteMessage(msg string, now time.Time) error {
+	tok, err := sw.key.Encrypt(msg, now)
+	if err != nil {
+		return err
+	}
+	return sw.framer.WriteFrame(sw.w, []byte(tok))
+}
+
+// TokenStreamReader reads a stream written by a TokenStreamWriter.
+type TokenStreamReader struct {
+	r      *bufio.Reader
+	key    *Key
+	framer Framer
+}
+
+// NewTokenStreamReader returns a TokenStreamReader that reads tokens
+// framed by f, or by NewlineFramer if f is nil, from r and decrypts them
+// with secret. It buffers r, so it may read past the last frame.
+func NewTokenStreamReader(r io.Reader, secret string, f Framer) (*TokenStreamReader, error) {
+	k, err := NewKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		f = NewlineFramer{}
+	}
+	return &TokenStreamReader{r: bufio.NewReader(r), key: k, framer: f}, nil
+}
+
+// ReadMessage reads and decrypts the next token. It returns io.EOF at
+// the end of the stream. See Decrypt.
+func (sr *TokenStreamReader) ReadMessage(now time.Time, ttl time.Duration) (string, error) {
+	tok, err := sr.framer.ReadFrame(sr.r)
+	if err != nil {
+		return "", err
+	}
+	return sr.key.Decrypt(string(tok), now, ttl)
+}
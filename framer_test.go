@@ -0,0 +1,101 @@
+package fernet
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStream(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	msgs := []string{"first", "", "third, with\nan embedded newline", strings.Repeat("x", 1000)}
+	for _, f := range []Framer{nil, NewlineFramer{}, LengthPrefixFramer{}} {
+		var buf bytes.Buffer
+		sw, err := NewTokenStreamWriter(&buf, secret, f)
+		if err != nil {
+			t.Fatalf("%T: NewTokenStreamWriter error: %s", f, err)
+		}
+		for _, msg := range msgs {
+			if err := sw.WriteMessage(msg, now); err != nil {
+				t.Fatalf("%T: write error: %s", f, err)
+			}
+		}
+		sr, err := NewTokenStreamReader(&buf, secret, f)
+		if err != nil {
+			t.Fatalf("%T: NewTokenStreamReader error: %s", f, err)
+		}
+		for _, want := range msgs {
+			got, err := sr.ReadMessage(now, time.Minute)
+			if err != nil {
+				t.Fatalf("%T: read error: %s", f, err)
+			}
+			if got != want {
+				t.Fatalf("%T: got %q, want %q", f, got, want)
+			}
+		}
+		if _, err := sr.ReadMessage(now, time.Minute); err != io.EOF {
+			t.Fatalf("%T: got error %v at the end, want %v", f, err, io.EOF)
+		}
+	}
+	if _, err := NewTokenStreamWriter(io.Discard, "bogus", nil); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestNewlineFramer(t *testing.T) {
+	// Without a trailing newline, with CRLF line endings, and from a
+	// reader that is not an io.ByteReader.
+	in := "one\r\ntwo\nthree"
+	r := struct{ io.Reader }{strings.NewReader(in)}
+	for _, want := range []string{"one", "two", "three"} {
+		got, err := NewlineFramer{}.ReadFrame(r)
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+		if string(got) != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if _, err := (NewlineFramer{}).ReadFrame(r); err != io.EOF {
+		t.Fatalf("got error %v, want %v", err, io.EOF)
+	}
+	var buf bytes.Buffer
+	if err := (NewlineFramer{}).WriteFrame(&buf, []byte("tok")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "tok\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "tok\n")
+	}
+}
+
+func TestLengthPrefixFramer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (LengthPrefixFramer{}).WriteFrame(&buf, []byte("tok")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x00\x00\x00\x03tok"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+	stream := buf.Bytes()
+	for _, tt := range []struct {
+		desc string
+		in   []byte
+		want error
+	}{
+		{"truncated length", stream[:2], io.ErrUnexpectedEOF},
+		{"truncated token", stream[:5], io.ErrUnexpectedEOF},
+		{"length only", stream[:4], io.ErrUnexpectedEOF},
+		{"empty", nil, io.EOF},
+	} {
+		if _, err := (LengthPrefixFramer{}).ReadFrame(bytes.NewReader(tt.in)); err != tt.want {
+			t.Errorf("%s: got error %v, want %v", tt.desc, err, tt.want)
+		}
+	}
+	huge := []byte{0xff, 0xff, 0xff, 0xff}
+	if _, err := (LengthPrefixFramer{}).ReadFrame(bytes.NewReader(huge)); err == nil {
+		t.Fatal("expected an error for an implausible length")
+	}
+}
@@ -0,0 +1,33 @@
+package fernet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// EncryptGob encodes v with encoding/gob and encrypts the result with
+// Encrypt. Encoding errors are returned as a *PayloadError. As with any
+// use of gob, concrete types stored in interface-typed fields must be
+// registered with gob.Register before encoding and decoding.
+func EncryptGob(v interface{}, secret string, now time.Time) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return "", &PayloadError{Op: "marshal", Err: err}
+	}
+	return Encrypt(buf.String(), secret, now)
+}
+
+// DecryptGob decrypts token with Decrypt and decodes the message into v,
+// which must be a pointer. Decoding errors are returned as a
+// *PayloadError; all other errors are those returned by Decrypt.
+func DecryptGob(token, secret string, now time.Time, ttl time.Duration, v interface{}) error {
+	msg, err := Decrypt(token, secret, now, ttl)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader([]byte(msg))).Decode(v); err != nil {
+		return &PayloadError{Op: "unmarshal", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package fernet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGobRoundTrip(t *testing.T) {
+	type entry struct {
+		Created time.Time
+		Counts  map[string]int
+	}
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	in := entry{Created: now.Add(-time.Hour), Counts: map[string]int{"a": 1, "b": 2}}
+	tok, err := EncryptGob(in, secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	var out entry
+	if err := DecryptGob(tok, secret, now, time.Minute, &out); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if !out.Created.Equal(in.Created) || len(out.Counts) != 2 || out.Counts["a"] != 1 || out.Counts["b"] != 2 {
+		t.Fatalf("wrong payload: got %+v, want %+v", out, in)
+	}
+
+	var pe *PayloadError
+	if _, err := EncryptGob(func() {}, secret, now); !errors.As(err, &pe) || pe.Op != "marshal" {
+		t.Fatalf("got error %v, want a marshal *PayloadError", err)
+	}
+	var s string
+	if err := DecryptGob(tok, secret, now, time.Minute, &s); !errors.As(err, &pe) || pe.Op != "unmarshal" {
+		t.Fatalf("got error %v, want an unmarshal *PayloadError", err)
+	}
+}
@@ -0,0 +1,56 @@
+package fernet
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// EncryptForHost is like Encrypt but binds the token to host, so that,
+// for example, a cookie issued for one host cannot be replayed on
+// another. The host is not stored in the token; it is signed along with
+// it, and the token must be decrypted with DecryptForHost and the same
+// host. Hosts are compared case-insensitively and without any port, so
+// "Example.com:8443" matches "example.com". This is an extension to the
+// Fernet spec: the token has its own version byte and cannot be
+// decrypted by other Fernet implementations.
+func EncryptForHost(msg, host, secret string, now time.Time) (string, error) {
+	h, err := normalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+	return seal(&format{version: versionHost, aad: []byte(h)}, nil, msg, secret, now, randomIV)
+}
+
+// DecryptForHost decrypts a token created by EncryptForHost, failing if
+// it was created for a host other than host. See Decrypt.
+func DecryptForHost(token, host, secret string, now time.Time, ttl time.Duration) (string, error) {
+	h, err := normalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+	msg, ts, _, err := open(&format{version: versionHost, aad: []byte(h)}, 0, token, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// Lowercases host and removes any port, brackets around an IPv6
+// address, and trailing dot.
+func normalizeHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", errors.New("fernet: empty host")
+	}
+	return host, nil
+}
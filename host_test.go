@@ -0,0 +1,64 @@
+package fernet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncryptForHost(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := EncryptForHost("hello", "Example.com:8443", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	for _, host := range []string{"example.com", "EXAMPLE.COM", "example.com:443", "example.com."} {
+		msg, err := DecryptForHost(tok, host, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: decrypt error: %s", host, err)
+		}
+		if msg != "hello" {
+			t.Fatalf("%s: wrong message: got %q, want %q", host, msg, "hello")
+		}
+	}
+	for _, host := range []string{"evil.com", "www.example.com", "example.co", "example.com.evil.com"} {
+		if _, err := DecryptForHost(tok, host, secret, now, time.Minute); err == nil {
+			t.Fatalf("%s: accepted a token issued for another host", host)
+		}
+	}
+	if _, err := DecryptForHost(tok, "example.com", secret, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("accepted an expired token")
+	}
+	if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+		t.Fatal("standard Decrypt accepted a host-bound token")
+	}
+	std, err := Encrypt("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptForHost(std, "example.com", secret, now, time.Minute); err == nil {
+		t.Fatal("DecryptForHost accepted a standard token")
+	}
+	if _, err := EncryptForHost("hello", ":80", secret, now); err == nil {
+		t.Fatal("expected an error for an empty host")
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"example.com", "example.com"},
+		{"Example.COM", "example.com"},
+		{"example.com:80", "example.com"},
+		{"example.com.", "example.com"},
+		{"127.0.0.1:8080", "127.0.0.1"},
+		{"[::1]:443", "::1"},
+		{"[::1]", "::1"},
+		{"::1", "::1"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeHost(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeHost(%q) = (%q, %v), want (%q, nil)", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"sync"
+	"time"
+)
+
+// DecryptBufferLen returns the size of the dst buffer DecryptBytesInto
+// needs for a token of tokenLen bytes. This is up to 16 bytes more than
+// the length of the message, since the buffer also holds the padding.
+func DecryptBufferLen(tokenLen int) int {
+	// The decoded length is up to two bytes less than DecodedLen
+	// reports, depending on the padding; exactly one candidate leaves a
+	// whole number of blocks for the ciphertext.
+	n := base64.URLEncoding.DecodedLen(tokenLen) - fixedLen
+	for ; n > 0; n-- {
+		if n%aes.BlockSize == 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// DecryptBytesInto is like Decrypt but takes the token and the 32 raw
+// bytes of the secret as byte slices and decrypts the message into dst,
+// returning its length. dst must be at least DecryptBufferLen(len(token))
+// bytes long; if the token is invalid, dst's contents are undefined. The
+// token must not contain newlines.
+//
+// DecryptBytesInto is meant for memory-constrained environments: when
+// it is called repeatedly with the same secret, it makes no heap
+// allocations unless it fails.
+func DecryptBytesInto(dst, token, secret []byte, now time.Time, ttl time.Duration) (int, error) {
+	if len(secret) != 2*keyLen {
+		return 0, ErrSecretWrongLength
+	}
+	// Work out the decoded length without decoding.
+	if len(token)%4 != 0 {
+		return 0, ErrTokenNotBase64
+	}
+	n := len(token) / 4 * 3
+	for i := len(token) - 1; i >= 0 && i >= len(token)-2 && token[i] == '='; i-- {
+		n--
+	}
+	if n < fixedLen+aes.BlockSize {
+		return 0, ErrTokenTooShort
+	}
+	textLen := n - fixedLen
+	if textLen%aes.BlockSize != 0 {
+		return 0, ErrCiphertextLength
+	}
+	if len(dst) < textLen {
+		return 0, errors.New("fernet: destination buffer is too small")
+	}
+	s := getIntoScratch(secret)
+	defer intoScratchPool.Put(s)
+
+	// Decode the token a piece at a time, copying the ciphertext to dst
+	// and the rest to head and msgMAC, and compute its HMAC.
+	var (
+		head   [msgOffset]byte
+		msgMAC [sha256.Size]byte
+		buf    = s.buf[:]
+		off    int // offset of buf[0] in the decoded token
+	)
+	for i := 0; i < len(token); i += 512 {
+		j := i + 512
+		if j > len(token) {
+			j = len(token)
+		}
+		m, err := base64.URLEncoding.Decode(buf[:], token[i:j])
+		if err != nil || (j < len(token) && m != len(buf)) {
+			return 0, ErrTokenNotBase64
+		}
+		// Each of the token's fields covers a range of offsets; copy
+		// the part of each range that falls within this chunk.
+		var (
+			chunk  = buf[:m]
+			macOff = n - sha256.Size
+			lo, hi int
+		)
+		if lo, hi = clamp(0, off, m), clamp(macOff, off, m); lo < hi {
+			_, _ = s.mac.Write(chunk[lo:hi])
+		}
+		if lo, hi = clamp(0, off, m), clamp(msgOffset, off, m); lo < hi {
+			copy(head[off+lo:], chunk[lo:hi])
+		}
+		if lo, hi = clamp(msgOffset, off, m), clamp(macOff, off, m); lo < hi {
+			copy(dst[off+lo-msgOffset:], chunk[lo:hi])
+		}
+		if lo, hi = clamp(macOff, off, m), clamp(n, off, m); lo < hi {
+			copy(msgMAC[off+lo-macOff:], chunk[lo:hi])
+		}
+		off += m
+	}
+	if off != n {
+		return 0, ErrTokenNotBase64
+	}
+	if head[0] != version {
+		return 0, ErrWrongVersion
+	}
+	if !hmac.Equal(msgMAC[:], s.mac.Sum(s.sum[:0])) {
+		return 0, ErrWrongHMAC
+	}
+	t := time.Unix(int64(binary.BigEndian.Uint64(head[tsOffset:])), 0)
+	md := Metadata{Timestamp: t, Age: now.Sub(t)}
+	switch {
+	case md.Age > ttl:
+		return 0, &ExpiredError{Metadata: md}
+	case md.Age < -maxClockSkew:
+		return 0, ErrClockSkew
+	}
+	// Decrypt in place, last block first, so that the previous block
+	// is still ciphertext when it is needed.
+	text := dst[:textLen]
+	for i := textLen - aes.BlockSize; i >= 0; i -= aes.BlockSize {
+		b := text[i : i+aes.BlockSize]
+		s.block.Decrypt(b, b)
+		prev := head[ivOffset:msgOffset]
+		if i > 0 {
+			prev = text[i-aes.BlockSize : i]
+		}
+		for j := range b {
+			b[j] ^= prev[j]
+		}
+	}
+	msg := unpad(text)
+	if msg == nil {
+		return 0, ErrInvalidPadding
+	}
+	return len(msg), nil
+}
+
+// Cipher state and buffers for DecryptBytesInto. Reusing it for the
+// same secret avoids the allocations of setting it up. The buffers are
+// kept here because passing them to the hash would move them to the
+// heap anyway.
+type intoScratch struct {
+	secret [2 * keyLen]byte
+	block  cipher.Block
+	mac    hash.Hash
+	buf    [384]byte // decoded size of 512 encoded bytes
+	sum    [sha256.Size]byte
+}
+
+var intoScratchPool sync.Pool // of *intoScratch
+
+// Returns scratch state for secret, ready for use, from the pool if
+// possible. The caller must return it to the pool when done.
+func getIntoScratch(secret []byte) *intoScratch {
+	s, _ := intoScratchPool.Get().(*intoScratch)
+	if s != nil && subtle.ConstantTimeCompare(s.secret[:], secret) == 1 {
+		s.mac.Reset()
+		return s
+	}
+	s = &intoScratch{}
+	copy(s.secret[:], secret)
+	s.block, _ = aes.NewCipher(s.secret[keyLen:])
+	s.mac = hmac.New(sha256.New, s.secret[:keyLen])
+	return s
+}
+
+// Returns the index within a chunk of m bytes, starting at offset off,
+// of the byte at offset p, clamped to the chunk.
+func clamp(p, off, m int) int {
+	switch {
+	case p < off:
+		return 0
+	case p > off+m:
+		return m
+	}
+	return p - off
+}
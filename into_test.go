@@ -0,0 +1,96 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecryptBytesInto(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	raw, _ := base64.URLEncoding.DecodeString(secret)
+	now := time.Now()
+	// Include messages longer than DecryptBytesInto's decoding buffer.
+	for _, n := range []int{0, 1, 15, 16, 17, 100, 383, 384, 385, 1000, 5000} {
+		msg := strings.Repeat("x", n)
+		tok, err := Encrypt(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		dst := make([]byte, DecryptBufferLen(len(tok)))
+		m, err := DecryptBytesInto(dst, []byte(tok), raw, now, time.Minute)
+		if err != nil {
+			t.Fatalf("n=%d: decrypt error: %s", n, err)
+		}
+		if string(dst[:m]) != msg {
+			t.Fatalf("n=%d: wrong message: got %q", n, dst[:m])
+		}
+		if _, err := DecryptBytesInto(dst[:len(dst)-1], []byte(tok), raw, now, time.Minute); err == nil {
+			t.Fatalf("n=%d: expected an error for a short buffer", n)
+		}
+	}
+
+	tok, _ := Encrypt("hello", secret, now)
+	dst := make([]byte, DecryptBufferLen(len(tok)))
+	if _, err := DecryptBytesInto(dst, []byte(tok), raw, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := DecryptBytesInto(dst, []byte(tok), raw[:31], now, time.Minute); err != ErrSecretWrongLength {
+		t.Fatalf("got error %v, want %v", err, ErrSecretWrongLength)
+	}
+	other, _ := base64.URLEncoding.DecodeString("wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=")
+	tests := []struct {
+		desc   string
+		token  string
+		secret []byte
+	}{
+		{"wrong secret", tok, other},
+		{"tampered", tok[:len(tok)-4] + "AA==", raw},
+		{"truncated", tok[:len(tok)-4], raw},
+		{"newline", tok[:20] + "\n" + tok[20:len(tok)-1], raw},
+		{"invalid base64", tok[:20] + "!" + tok[21:], raw},
+		{"too short", tok[:MinTokenLen()-4], raw},
+	}
+	for _, tt := range tests {
+		if _, err := DecryptBytesInto(dst, []byte(tt.token), tt.secret, now, time.Minute); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+}
+
+func TestDecryptBytesIntoAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	raw, _ := base64.URLEncoding.DecodeString(secret)
+	now := time.Now()
+	tok, _ := Encrypt(strings.Repeat("x", 1000), secret, now)
+	token := []byte(tok)
+	dst := make([]byte, DecryptBufferLen(len(token)))
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := DecryptBytesInto(dst, token, raw, now, time.Minute); err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("got %v allocs per call, want 0", allocs)
+	}
+}
+
+func BenchmarkDecryptBytesInto(b *testing.B) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	raw, _ := base64.URLEncoding.DecodeString(secret)
+	now := time.Now()
+	tok, _ := Encrypt("hello, world", secret, now)
+	token := []byte(tok)
+	dst := make([]byte, DecryptBufferLen(len(token)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecryptBytesInto(dst, token, raw, now, time.Minute); err != nil {
+			b.Fatalf("decrypt error: %s", err)
+		}
+	}
+}
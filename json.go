@@ -0,0 +1,44 @@
+package fernet
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PayloadError reports a failure to marshal or unmarshal the payload of
+// EncryptJSON, DecryptJSON, EncryptGob, or DecryptGob. It lets callers
+// tell a malformed payload apart from a token that failed verification.
+type PayloadError struct {
+	Op  string // "marshal" or "unmarshal"
+	Err error
+}
+
+func (e *PayloadError) Error() string {
+	return "fernet: " + e.Op + " payload: " + e.Err.Error()
+}
+
+func (e *PayloadError) Unwrap() error { return e.Err }
+
+// EncryptJSON marshals v as JSON and encrypts the result with Encrypt.
+// Marshaling errors are returned as a *PayloadError.
+func EncryptJSON(v interface{}, secret string, now time.Time) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", &PayloadError{Op: "marshal", Err: err}
+	}
+	return Encrypt(string(b), secret, now)
+}
+
+// DecryptJSON decrypts token with Decrypt and unmarshals the message
+// into v. Unmarshaling errors are returned as a *PayloadError; all other
+// errors are those returned by Decrypt.
+func DecryptJSON(token, secret string, now time.Time, ttl time.Duration, v interface{}) error {
+	msg, err := Decrypt(token, secret, now, ttl)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(msg), v); err != nil {
+		return &PayloadError{Op: "unmarshal", Err: err}
+	}
+	return nil
+}
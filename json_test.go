@@ -0,0 +1,40 @@
+package fernet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	type session struct {
+		UserID int64    `json:"uid"`
+		Roles  []string `json:"roles"`
+	}
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	in := session{UserID: 42, Roles: []string{"admin", "ops"}}
+	tok, err := EncryptJSON(in, secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	var out session
+	if err := DecryptJSON(tok, secret, now, time.Minute, &out); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if out.UserID != in.UserID || len(out.Roles) != 2 || out.Roles[0] != "admin" || out.Roles[1] != "ops" {
+		t.Fatalf("wrong payload: got %+v, want %+v", out, in)
+	}
+
+	var pe *PayloadError
+	if _, err := EncryptJSON(make(chan int), secret, now); !errors.As(err, &pe) || pe.Op != "marshal" {
+		t.Fatalf("got error %v, want a marshal *PayloadError", err)
+	}
+	var n int
+	if err := DecryptJSON(tok, secret, now, time.Minute, &n); !errors.As(err, &pe) || pe.Op != "unmarshal" {
+		t.Fatalf("got error %v, want an unmarshal *PayloadError", err)
+	}
+	if err := DecryptJSON(tok, secret, now.Add(time.Hour), time.Minute, &out); !errors.Is(err, ErrExpired) || errors.As(err, &pe) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+}
@@ -0,0 +1,80 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"sync"
+	"time"
+)
+
+// Key is a decoded secret. The functions that accept a secret string
+// decode it and set up the cipher and HMAC on every call; a Key does so
+// only once, and it reuses HMAC state between calls, which makes it
+// cheaper when many tokens are created or decrypted with one secret. A
+// Key is safe for concurrent use.
+type Key struct {
+	block cipher.Block
+	macs  sync.Pool // of HMAC-SHA256 hashes keyed with the signing key
+}
+
+// NewKey decodes secret, which must be valid for Encrypt, and returns
+// the corresponding Key.
+func NewKey(secret string) (*Key, error) {
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := aes.NewCipher(encryptionKey)
+	k := &Key{block: block}
+	k.macs.New = func() interface{} { return hmac.New(sha256.New, signingKey) }
+	return k, nil
+}
+
+// NewKeyStrict is like NewKey but rejects a secret that fails
+// ValidateSecretStrict.
+func NewKeyStrict(secret string) (*Key, error) {
+	if err := ValidateSecretStrict(secret); err != nil {
+		return nil, err
+	}
+	return NewKey(secret)
+}
+
+// Encrypt encrypts and signs msg. See Encrypt.
+func (k *Key) Encrypt(msg string, now time.Time) (string, error) {
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return "", err
+	}
+	tok := make([]byte, paddedLen(len(msg))+fixedLen)
+	padString(tok[msgOffset:], msg)
+	mac := k.getMAC()
+	defer k.macs.Put(mac)
+	return encryptPadded(tok, mac, k.block, &EncryptOptions{Now: now}, randomIV)
+}
+
+// Decrypt verifies and decrypts token. See Decrypt.
+func (k *Key) Decrypt(token string, now time.Time, ttl time.Duration) (string, error) {
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	tok, err := opts.decodeToken(token)
+	if err != nil {
+		return "", err
+	}
+	mac := k.getMAC()
+	defer k.macs.Put(mac)
+	msg, _, err := decryptToken(tok, mac, k.block, &opts)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// Returns an HMAC from the pool, ready for use. The caller must return
+// it to the pool when done. A hash.Hash is not safe for concurrent use,
+// but the pool never hands the same one to two goroutines at once.
+func (k *Key) getMAC() hash.Hash {
+	mac := k.macs.Get().(hash.Hash)
+	mac.Reset()
+	return mac
+}
@@ -0,0 +1,78 @@
+package fernet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	k, err := NewKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, msg := range []string{"", "hello", "a message that spans more than one block"} {
+		tok, err := k.Encrypt(msg, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		// Key and the stateless functions must be interchangeable.
+		if got, err := Decrypt(tok, secret, now, time.Minute); err != nil || got != msg {
+			t.Fatalf("Decrypt: got (%q, %v), want (%q, nil)", got, err, msg)
+		}
+		tok, err = Encrypt(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		if got, err := k.Decrypt(tok, now, time.Minute); err != nil || got != msg {
+			t.Fatalf("Key.Decrypt: got (%q, %v), want (%q, nil)", got, err, msg)
+		}
+	}
+	if _, err := NewKey("bogus"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// Verifies that a pooled HMAC left in a dirty state by a failed
+// verification does not affect later calls.
+func TestKeyReusesMAC(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		forged = "gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykQUFBQUFBQUFBQQ=="
+	)
+	now := time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+	k, err := NewKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := k.Decrypt(forged, now, time.Minute); err == nil {
+			t.Fatal("expected an error")
+		}
+		if msg, err := k.Decrypt(token, now, time.Minute); err != nil || msg != "hello" {
+			t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+		}
+	}
+}
+
+func BenchmarkKeyDecrypt(b *testing.B) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	k, err := NewKey(secret)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tok, err := k.Encrypt("hello, world", now)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := k.Decrypt(tok, now, time.Minute); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultKeyCacheSize is the number of secrets whose decoded keys
+// Encrypt and Decrypt keep by default. See SetKeyCacheSize.
+const DefaultKeyCacheSize = 16
+
+// SetKeyCacheSize sets the number of secrets whose decoded keys and AES
+// ciphers are cached by Encrypt, Decrypt, and the functions built on
+// them, so that repeated calls with the same secret skip decoding it.
+// When the cache is full, a secret that has not been used recently is
+// evicted; the choice is approximate, so that lookups need not
+// serialize on a lock. A size of zero or less disables the cache and
+// empties it.
+//
+// Each cached secret costs a few hundred bytes, and a cached secret
+// stays in memory, decoded, until it is evicted, even after the caller
+// has discarded it; disable the cache if that matters. A Key avoids the
+// decoding without any global state.
+func SetKeyCacheSize(n int) {
+	secretCache.mu.Lock()
+	defer secretCache.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	secretCache.size = n
+	for len(secretCache.entries) > n {
+		secretCache.evict()
+	}
+}
+
+// The decoded keys of a secret.
+type cachedKeys struct {
+	signing, encryption []byte
+	block               cipher.Block
+	used                atomic.Uint64 // epoch of the last use
+}
+
+// A cache of decoded secrets, safe for concurrent use. Lookups share a
+// read lock and record when each entry was last used as an epoch,
+// which advances whenever a secret is added. Eviction removes the entry
+// with the oldest epoch; entries used in the same epoch are equally
+// likely to go, which is what makes the LRU order approximate.
+type keyCache struct {
+	mu      sync.RWMutex
+	size    int
+	entries map[string]*cachedKeys
+	epoch   atomic.Uint64
+}
+
+var secretCache = keyCache{
+	size:    DefaultKeyCacheSize,
+	entries: make(map[string]*cachedKeys),
+}
+
+// Returns the decoded keys of secret and a cipher keyed with the
+// encryption key, from the cache if possible. The keys are shared, so
+// the caller must not modify them.
+func (c *keyCache) get(secret string) (*cachedKeys, error) {
+	c.mu.RLock()
+	k, ok := c.entries[secret]
+	c.mu.RUnlock()
+	if ok {
+		// Skip the store if it would not change anything, so that
+		// goroutines sharing a secret do not contend for its entry.
+		if epoch := c.epoch.Load(); k.used.Load() != epoch {
+			k.used.Store(epoch)
+		}
+		return k, nil
+	}
+	signing, encryption, err := extractKeys(secret)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := aes.NewCipher(encryption)
+	k = &cachedKeys{signing: signing, encryption: encryption, block: block}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size == 0 {
+		return k, nil
+	}
+	// Another goroutine may have added the secret in the meantime.
+	if prev, ok := c.entries[secret]; ok {
+		return prev, nil
+	}
+	for len(c.entries) >= c.size {
+		c.evict()
+	}
+	// Skip an epoch, so that the new entry ranks after every earlier
+	// use but before any later one.
+	k.used.Store(c.epoch.Add(2) - 1)
+	c.entries[secret] = k
+	return k, nil
+}
+
+// Removes the entry used longest ago. c.mu must be held.
+func (c *keyCache) evict() {
+	var (
+		oldest string
+		min    uint64
+		first  = true
+	)
+	for secret, k := range c.entries {
+		if used := k.used.Load(); first || used < min {
+			oldest, min, first = secret, used, false
+		}
+	}
+	delete(c.entries, oldest)
+}
@@ -0,0 +1,176 @@
+package fernet
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Returns the secrets in the cache, in sorted order.
+func cachedSecrets() []string {
+	secretCache.mu.RLock()
+	defer secretCache.mu.RUnlock()
+	var secrets []string
+	for secret := range secretCache.entries {
+		secrets = append(secrets, secret)
+	}
+	sort.Strings(secrets)
+	return secrets
+}
+
+func TestKeyCache(t *testing.T) {
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	secrets := []string{
+		"cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+	}
+	now := time.Now()
+	// Each call looks the secret up once, so the uses below fall in
+	// different epochs and the eviction order is exact.
+	use := func(secret string) {
+		t.Helper()
+		if _, err := Encrypt("hello", secret, now); err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+	}
+	check := func(want ...string) {
+		t.Helper()
+		sort.Strings(want)
+		got := cachedSecrets()
+		if len(got) != len(want) {
+			t.Fatalf("cache holds %d secrets, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("cache entry %d is %q, want %q", i, got[i], want[i])
+			}
+		}
+	}
+
+	SetKeyCacheSize(0)
+	SetKeyCacheSize(2)
+	check()
+	use(secrets[0])
+	use(secrets[1])
+	check(secrets[0], secrets[1])
+	use(secrets[0])
+	use(secrets[2]) // evicts secrets[1]
+	check(secrets[0], secrets[2])
+
+	// Invalid secrets are not cached.
+	if _, err := Encrypt("hello", "bogus", now); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+	check(secrets[0], secrets[2])
+
+	// Hex secrets bypass the cache.
+	opts := DecryptOptions{Now: now, TTL: time.Minute, HexSecret: true}
+	if _, err := DecryptWithOptions("gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA==", "730ff4c7af3d46923e8ed451ee813c87f790b0a226bc96a92de49b5e9c05e1ee", opts); err == nil {
+		t.Fatal("expected the spec's token to have expired")
+	}
+	check(secrets[0], secrets[2])
+
+	SetKeyCacheSize(1)
+	check(secrets[2])
+	SetKeyCacheSize(0)
+	check()
+	use(secrets[0])
+	check()
+}
+
+func TestKeyCacheConcurrent(t *testing.T) {
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	SetKeyCacheSize(2)
+	secrets := []string{
+		"cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+	}
+	now := time.Now()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				secret := secrets[(g+i)%len(secrets)]
+				tok, err := Encrypt("hello", secret, now)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := Decrypt(tok, secret, now, time.Minute); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%50 == 0 {
+					SetKeyCacheSize(1 + i%3)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkEncryptNoKeyCache(b *testing.B) {
+	SetKeyCacheSize(0)
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	benchmarkEncrypt(b, "hello, world")
+}
+
+func BenchmarkDecryptNoKeyCache(b *testing.B) {
+	SetKeyCacheSize(0)
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	benchmarkDecrypt(b, "hello, world")
+}
+
+// The cache must not serialize goroutines that share a secret; compare
+// with the NoKeyCache variants, which share nothing.
+func BenchmarkEncryptParallel(b *testing.B) {
+	benchmarkParallel(b, func(secret string, now time.Time) error {
+		_, err := Encrypt("hello, world", secret, now)
+		return err
+	})
+}
+
+func BenchmarkEncryptParallelNoKeyCache(b *testing.B) {
+	SetKeyCacheSize(0)
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	BenchmarkEncryptParallel(b)
+}
+
+func BenchmarkDecryptParallel(b *testing.B) {
+	secret := "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := Encrypt("hello, world", secret, now)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkParallel(b, func(secret string, now time.Time) error {
+		_, err := Decrypt(tok, secret, now, time.Minute)
+		return err
+	})
+}
+
+func BenchmarkDecryptParallelNoKeyCache(b *testing.B) {
+	SetKeyCacheSize(0)
+	defer SetKeyCacheSize(DefaultKeyCacheSize)
+	BenchmarkDecryptParallel(b)
+}
+
+func benchmarkParallel(b *testing.B, f func(secret string, now time.Time) error) {
+	secret := "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := f(secret, now); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
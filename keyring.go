@@ -0,0 +1,204 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyRing holds a set of secrets, each identified by a key ID. The most
+// recently added secret is the primary, which is used for encryption;
+// all of them are used for decryption. A KeyRing is safe for concurrent
+// use, so secrets can be added while it is in use.
+//
+// Tokens created with EncryptWithID carry the ID of the secret used to
+// create them, which lets Decrypt select the right secret directly
+// rather than trying each in turn. This is an extension to the Fernet
+// spec; such tokens cannot be decrypted by other implementations.
+type KeyRing struct {
+	mu      sync.RWMutex
+	ids     []string // in the order added
+	secrets map[string]string
+}
+
+// NewKeyRing returns an empty KeyRing.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{secrets: make(map[string]string)}
+}
+
+// Add adds secret to the key ring with the given ID and makes it the
+// primary. id must be between 1 and 255 bytes long and not already in
+// use.
+func (kr *KeyRing) Add(id, secret string) error {
+	if len(id) == 0 || len(id) > 255 {
+		return errors.New("fernet: key ID must be between 1 and 255 bytes")
+	}
+	if _, _, err := extractKeys(secret); err != nil {
+		return err
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.secrets[id]; ok {
+		return errors.New("fernet: duplicate key ID")
+	}
+	kr.ids = append(kr.ids, id)
+	kr.secrets[id] = secret
+	return nil
+}
+
+// Primary returns the ID of the primary secret, or the empty string if
+// the key ring is empty.
+func (kr *KeyRing) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.ids) == 0 {
+		return ""
+	}
+	return kr.ids[len(kr.ids)-1]
+}
+
+// Current returns the primary secret. It implements SecretProvider.
+func (kr *KeyRing) Current() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.ids) == 0 {
+		return ""
+	}
+	return kr.secrets[kr.ids[len(kr.ids)-1]]
+}
+
+// All returns every secret, newest first. It implements SecretProvider.
+func (kr *KeyRing) All() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	secrets := make([]string, len(kr.ids))
+	for i, id := range kr.ids {
+		secrets[len(secrets)-1-i] = kr.secrets[id]
+	}
+	return secrets
+}
+
+// AddNamed is like Add but takes the key ID and secret as a single
+// string of the form "keyID:secret". See ParseNamedSecret.
+func (kr *KeyRing) AddNamed(s string) error {
+	id, secret, err := ParseNamedSecret(s)
+	if err != nil {
+		return err
+	}
+	return kr.Add(id, secret)
+}
+
+// Returns the IDs of the secrets in the order added. Because Add only
+// ever appends, the result is safe to read after the lock is released.
+func (kr *KeyRing) keyIDs() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.ids[:len(kr.ids):len(kr.ids)]
+}
+
+// Returns the secret with the given ID.
+func (kr *KeyRing) secret(id string) (string, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	secret, ok := kr.secrets[id]
+	return secret, ok
+}
+
+// Encrypt encrypts msg with the primary secret, producing a standard
+// token. See Encrypt.
+func (kr *KeyRing) Encrypt(msg string, now time.Time) (string, error) {
+	return Encrypt(msg, kr.Current(), now)
+}
+
+// EncryptWithID is like Encrypt but records the primary secret's ID in
+// the token.
+func (kr *KeyRing) EncryptWithID(msg string, now time.Time) (string, error) {
+	kr.mu.RLock()
+	if len(kr.ids) == 0 {
+		kr.mu.RUnlock()
+		return "", errors.New("fernet: no secrets")
+	}
+	id := kr.ids[len(kr.ids)-1]
+	secret := kr.secrets[id]
+	kr.mu.RUnlock()
+	header := append([]byte{byte(len(id))}, id...)
+	return seal(&format{version: versionKeyID}, header, msg, secret, now, randomIV)
+}
+
+// Decrypt decrypts token. If it was created by EncryptWithID, the
+// secret with the recorded ID is used; otherwise each secret is tried
+// in turn, newest first. See Decrypt.
+func (kr *KeyRing) Decrypt(token string, now time.Time, ttl time.Duration) (string, error) {
+	id, ok := tokenKeyID(token)
+	if !ok {
+		return decryptAny(token, kr.All(), now, ttl)
+	}
+	kr.mu.RLock()
+	secret, ok := kr.secrets[id]
+	kr.mu.RUnlock()
+	if !ok {
+		return "", errors.New("fernet: unknown key ID")
+	}
+	msg, ts, _, err := open(&format{version: versionKeyID}, 1+len(id), token, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// Returns the (unauthenticated) key ID from a token created by
+// EncryptWithID. ok is false if token is not such a token.
+func tokenKeyID(token string) (id string, ok bool) {
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil || len(tok) <= tsOffset+tsLen || tok[0] != versionKeyID {
+		return "", false
+	}
+	n := int(tok[tsOffset+tsLen])
+	header := tok[tsOffset+tsLen+1:]
+	if n > len(header) {
+		return "", false
+	}
+	return string(header[:n]), true
+}
+
+// ExportKeyRing serializes secrets, in order, and encrypts them with
+// wrappingSecret, so that a set of rotation secrets can be moved between
+// environments as a single token. Use ImportKeyRing to recover them.
+// The bundle has its own version byte, so it cannot be mistaken for an
+// application's token even if wrappingSecret is used for those too;
+// Decrypt rejects it.
+func ExportKeyRing(secrets []string, wrappingSecret string, now time.Time) (string, error) {
+	if len(secrets) == 0 {
+		return "", errors.New("fernet: no secrets")
+	}
+	for i, secret := range secrets {
+		if err := ValidateSecret(secret); err != nil {
+			return "", fmt.Errorf("fernet: secret %d: %w", i, err)
+		}
+	}
+	return seal(&format{version: versionBundle}, nil, strings.Join(secrets, "\n"), wrappingSecret, now, randomIV)
+}
+
+// ImportKeyRing decrypts a bundle created by ExportKeyRing and returns
+// the secrets in the order they were exported. The bundle does not
+// expire; it fails verification if wrappingSecret is not the one it was
+// exported with.
+func ImportKeyRing(bundle, wrappingSecret string) ([]string, error) {
+	msg, _, _, err := open(&format{version: versionBundle}, 0, bundle, wrappingSecret)
+	if err != nil {
+		return nil, err
+	}
+	secrets := strings.Split(string(msg), "\n")
+	for i, secret := range secrets {
+		if err := ValidateSecret(secret); err != nil {
+			return nil, fmt.Errorf("fernet: secret %d: %w", i, err)
+		}
+	}
+	return secrets, nil
+}
@@ -0,0 +1,158 @@
+package fernet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRing(t *testing.T) {
+	const (
+		secret1 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret2 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+		secret3 = "DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g="
+	)
+	now := time.Now()
+	kr := NewKeyRing()
+	if kr.Primary() != "" {
+		t.Fatalf("empty key ring has primary %q", kr.Primary())
+	}
+	if _, err := kr.EncryptWithID("hello", now); err == nil {
+		t.Fatal("expected an error from an empty key ring")
+	}
+	if err := kr.Add("2016", secret1); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.Add("2017", secret2); err != nil {
+		t.Fatal(err)
+	}
+	if got := kr.Primary(); got != "2017" {
+		t.Fatalf("got primary %q, want %q", got, "2017")
+	}
+	for _, tt := range []struct{ id, secret string }{{"", secret3}, {"2018", "bogus"}, {"2017", secret3}} {
+		if err := kr.Add(tt.id, tt.secret); err == nil {
+			t.Fatalf("Add(%q, %q): expected an error", tt.id, tt.secret)
+		}
+	}
+
+	// Direct selection: a token carrying a key ID.
+	tok, err := kr.EncryptWithID("direct", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if id, ok := tokenKeyID(tok); !ok || id != "2017" {
+		t.Fatalf("got key ID (%q, %t), want (%q, true)", id, ok, "2017")
+	}
+	if msg, err := kr.Decrypt(tok, now, time.Minute); err != nil || msg != "direct" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "direct")
+	}
+	if _, err := kr.Decrypt(tok, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("expected an expiry error")
+	}
+	if _, err := Decrypt(tok, secret2, now, time.Minute); err == nil {
+		t.Fatal("standard Decrypt accepted a key ID token")
+	}
+
+	// A key ID token whose secret is unknown.
+	other := NewKeyRing()
+	if err := other.Add("2015", secret3); err != nil {
+		t.Fatal(err)
+	}
+	tok, err = other.EncryptWithID("unknown", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := kr.Decrypt(tok, now, time.Minute); err == nil {
+		t.Fatal("expected an error for an unknown key ID")
+	}
+
+	// A key ID token signed with a different secret under a known ID.
+	other = NewKeyRing()
+	if err := other.Add("2016", secret3); err != nil {
+		t.Fatal(err)
+	}
+	tok, err = other.EncryptWithID("forged", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := kr.Decrypt(tok, now, time.Minute); err == nil {
+		t.Fatal("expected an error for a mismatched secret")
+	}
+
+	// Fallback: standard tokens are tried against every secret.
+	for _, secret := range []string{secret1, secret2} {
+		tok, err := Encrypt("fallback", secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		if msg, err := kr.Decrypt(tok, now, time.Minute); err != nil || msg != "fallback" {
+			t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "fallback")
+		}
+	}
+	tok, err = kr.Encrypt("standard", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := Decrypt(tok, secret2, now, time.Minute); err != nil || msg != "standard" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "standard")
+	}
+}
+
+func TestKeyRingAddNamed(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	kr := NewKeyRing()
+	if err := kr.AddNamed("2017:" + secret); err != nil {
+		t.Fatalf("AddNamed error: %s", err)
+	}
+	if id, cur := kr.Primary(), kr.Current(); id != "2017" || cur != secret {
+		t.Fatalf("got (%q, %q), want (%q, %q)", id, cur, "2017", secret)
+	}
+	if err := kr.AddNamed("2017:" + secret); err == nil {
+		t.Fatal("expected an error for a duplicate key ID")
+	}
+	if err := kr.AddNamed(secret); err == nil {
+		t.Fatal("expected an error for a secret without a key ID")
+	}
+}
+
+func TestExportKeyRing(t *testing.T) {
+	const wrapping = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	secrets := []string{
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+		"DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g=",
+	}
+	bundle, err := ExportKeyRing(secrets, wrapping, time.Now())
+	if err != nil {
+		t.Fatalf("export error: %s", err)
+	}
+	got, err := ImportKeyRing(bundle, wrapping)
+	if err != nil {
+		t.Fatalf("import error: %s", err)
+	}
+	if !reflect.DeepEqual(got, secrets) {
+		t.Fatalf("got %q, want %q", got, secrets)
+	}
+	if _, err := ImportKeyRing(bundle, secrets[0]); !errors.Is(err, ErrWrongHMAC) {
+		t.Fatalf("got error %v with the wrong wrapping secret, want %v", err, ErrWrongHMAC)
+	}
+	for _, bad := range [][]string{nil, {secrets[0], "bogus"}} {
+		if _, err := ExportKeyRing(bad, wrapping, time.Now()); err == nil {
+			t.Fatalf("ExportKeyRing(%q): expected an error", bad)
+		}
+	}
+	// Bundles and application tokens cannot be confused, even with the
+	// same secret.
+	if _, err := Decrypt(bundle, wrapping, time.Now(), time.Minute); err != ErrWrongVersion {
+		t.Fatalf("got error %v decrypting a bundle, want %v", err, ErrWrongVersion)
+	}
+	tok, err := Encrypt(strings.Join(secrets, "\n"), wrapping, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportKeyRing(tok, wrapping); err != ErrWrongVersion {
+		t.Fatalf("got error %v importing a token, want %v", err, ErrWrongVersion)
+	}
+}
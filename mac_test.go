@@ -0,0 +1,46 @@
+package fernet
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestEncryptWithMAC(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, msg := range []string{"", "hello", "a message that spans more than one block"} {
+		tok, err := EncryptWithMAC(msg, secret, now, sha512.New)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		raw, err := base64.URLEncoding.DecodeString(tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 1 + 8 + 16 + paddedLen(len(msg)) + sha512.Size; len(raw) != want {
+			t.Fatalf("token is %d bytes, want %d", len(raw), want)
+		}
+		got, err := DecryptWithMAC(tok, secret, now, time.Minute, sha512.New)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if got != msg {
+			t.Fatalf("wrong message: got %q, want %q", got, msg)
+		}
+		if _, err := DecryptWithMAC(tok, secret, now, time.Minute, sha256.New); err == nil {
+			t.Fatal("token was verified with the wrong hash function")
+		}
+		if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+			t.Fatal("standard Decrypt accepted the token")
+		}
+		if _, err := DecryptWithMAC(tok, secret, now.Add(time.Hour), time.Minute, sha512.New); err == nil {
+			t.Fatal("expected an expiry error")
+		}
+	}
+	if _, err := EncryptWithMAC("hello", secret, now, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
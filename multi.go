@@ -0,0 +1,224 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MultiFernet encrypts with a primary secret and decrypts with any of a
+// list of secrets. This allows a secret to be rotated without
+// invalidating the tokens generated with its predecessors. A
+// MultiFernet is immutable and therefore safe for concurrent use.
+type MultiFernet struct {
+	secrets []string
+}
+
+// NewMultiFernet returns a MultiFernet whose primary secret is the first
+// of secrets. The remaining secrets are used only for decryption.
+func NewMultiFernet(secrets ...string) *MultiFernet {
+	return &MultiFernet{secrets: append([]string(nil), secrets...)}
+}
+
+// Encrypt encrypts msg with the primary secret. See Encrypt.
+func (mf *MultiFernet) Encrypt(msg string, now time.Time) (string, error) {
+	if len(mf.secrets) == 0 {
+		return "", errors.New("fernet: no secrets")
+	}
+	return Encrypt(msg, mf.secrets[0], now)
+}
+
+// Decrypt tries each secret in order, returning the message from the
+// first one that successfully decrypts token. If none does, the error
+// from the primary secret is returned. See Decrypt.
+func (mf *MultiFernet) Decrypt(token string, now time.Time, ttl time.Duration) (string, error) {
+	return decryptAny(token, mf.secrets, now, ttl)
+}
+
+// WithPrimary returns a new MultiFernet with secret as its primary and
+// all of mf's secrets retained for decryption. If secret is already one
+// of mf's secrets, it is moved to the front rather than duplicated. mf
+// is not modified, so callers can rotate keys by atomically swapping a
+// pointer to the result.
+func (mf *MultiFernet) WithPrimary(secret string) *MultiFernet {
+	secrets := make([]string, 1, len(mf.secrets)+1)
+	secrets[0] = secret
+	for _, s := range mf.secrets {
+		if s != secret {
+			secrets = append(secrets, s)
+		}
+	}
+	return &MultiFernet{secrets: secrets}
+}
+
+// Current returns mf's primary secret. It implements SecretProvider.
+func (mf *MultiFernet) Current() string {
+	if len(mf.secrets) == 0 {
+		return ""
+	}
+	return mf.secrets[0]
+}
+
+// All returns all of mf's secrets, primary first. It implements
+// SecretProvider.
+func (mf *MultiFernet) All() []string {
+	return append([]string(nil), mf.secrets...)
+}
+
+// Tries to decrypt token with each secret in turn. Returns the error
+// from the first secret if none succeeds.
+func decryptAny(token string, secrets []string, now time.Time, ttl time.Duration) (string, error) {
+	msg, _, err := DecryptWhich(token, secrets, now, ttl)
+	return msg, err
+}
+
+// DecryptWhich tries each secret in order, returning the message from
+// the first one that successfully decrypts token along with that
+// secret's index. If none does, the error from the first secret is
+// returned. See Decrypt.
+func DecryptWhich(token string, secrets []string, now time.Time, ttl time.Duration) (string, int, error) {
+	if len(secrets) == 0 {
+		return "", -1, errors.New("fernet: no secrets")
+	}
+	var firstErr error
+	for i, secret := range secrets {
+		msg, err := Decrypt(token, secret, now, ttl)
+		if err == nil {
+			return msg, i, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", -1, firstErr
+}
+
+// A Policy pairs a secret with the TTL of the tokens it decrypts.
+type Policy struct {
+	Secret string
+	TTL    time.Duration
+}
+
+// DecryptPolicies is like DecryptWhich but each secret has its own TTL,
+// e.g. when older key generations issued longer-lived tokens than the
+// current one. It tries each policy in order and returns the message
+// from the first one under which token is valid. If none is, and token
+// is authentic but expired under some policy, the error from the first
+// such policy is returned; otherwise the error from the first policy is.
+func DecryptPolicies(token string, policies []Policy, now time.Time) (string, error) {
+	if len(policies) == 0 {
+		return "", errors.New("fernet: no secrets")
+	}
+	var firstErr, expiredErr error
+	for _, p := range policies {
+		msg, err := Decrypt(token, p.Secret, now, p.TTL)
+		if err == nil {
+			return msg, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if expiredErr == nil && errors.Is(err, ErrExpired) {
+			expiredErr = err
+		}
+	}
+	if expiredErr != nil {
+		return "", expiredErr
+	}
+	return "", firstErr
+}
+
+// DecryptWithFingerprint is like DecryptWhich but identifies the secret
+// that decrypted token by its fingerprint rather than its index. See
+// KeyFingerprint.
+func DecryptWithFingerprint(token string, secrets []string, now time.Time, ttl time.Duration) (msg, fingerprint string, err error) {
+	msg, i, err := DecryptWhich(token, secrets, now, ttl)
+	if err != nil {
+		return "", "", err
+	}
+	fingerprint, err = KeyFingerprint(secrets[i])
+	if err != nil {
+		return "", "", err
+	}
+	return msg, fingerprint, nil
+}
+
+// EncryptForSecrets encrypts msg once for each of secrets, returning
+// the tokens in the same order. The tokens share a timestamp but each
+// has its own IV, so they are independent of one another. This allows a
+// message to be handed out ahead of a rotation to verifiers that each
+// hold a different secret.
+func EncryptForSecrets(msg string, secrets []string, now time.Time) ([]string, error) {
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return nil, err
+	}
+	var (
+		opts   = EncryptOptions{Now: now}
+		text   = padString(make([]byte, paddedLen(len(msg))), msg)
+		tokens = make([]string, len(secrets))
+	)
+	for i, secret := range secrets {
+		signingKey, encryptionKey, err := extractKeys(secret)
+		if err != nil {
+			return nil, err
+		}
+		tok := make([]byte, len(text)+fixedLen)
+		copy(tok[msgOffset:], text)
+		block, _ := aes.NewCipher(encryptionKey)
+		if tokens[i], err = encryptPadded(tok, hmac.New(sha256.New, signingKey), block, &opts, randomIV); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// EncryptFanout is like EncryptForSecrets but takes a map of secrets
+// keyed by recipient and returns each recipient's token under the same
+// key. A single buffer is reused for every token, so this is cheaper
+// than encrypting msg separately for each recipient. If any secret is
+// invalid, the error names its recipient and no tokens are returned.
+func EncryptFanout(msg string, recipientSecrets map[string]string, now time.Time) (map[string]string, error) {
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return nil, err
+	}
+	var (
+		opts   = EncryptOptions{Now: now}
+		tok    = make([]byte, paddedLen(len(msg))+fixedLen)
+		text   = padString(make([]byte, paddedLen(len(msg))), msg)
+		tokens = make(map[string]string, len(recipientSecrets))
+	)
+	for recipient, secret := range recipientSecrets {
+		signingKey, encryptionKey, err := extractKeys(secret)
+		if err != nil {
+			return nil, fmt.Errorf("fernet: recipient %q: %w", recipient, err)
+		}
+		// The previous token was encrypted in place, so restore the
+		// padded plaintext.
+		copy(tok[msgOffset:], text)
+		block, _ := aes.NewCipher(encryptionKey)
+		if tokens[recipient], err = encryptPadded(tok, hmac.New(sha256.New, signingKey), block, &opts, randomIV); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// MatchingSecrets returns the indices of every secret that successfully
+// decrypts token. Normally there is at most one, but duplicated secrets
+// yield more, which makes this useful for diagnosing a misconfigured
+// rotation. The plaintext is discarded.
+func MatchingSecrets(token string, secrets []string, now time.Time, ttl time.Duration) []int {
+	var (
+		indices []int
+		opts    = DecryptOptions{Now: now, TTL: ttl}
+	)
+	for i, secret := range secrets {
+		if _, _, err := decrypt(token, secret, &opts); err == nil {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
@@ -0,0 +1,274 @@
+package fernet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiFernetDecrypt(t *testing.T) {
+	const (
+		secret1 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret2 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+		secret3 = "DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g="
+	)
+	now := time.Now()
+	mf := NewMultiFernet(secret1, secret2)
+	for _, secret := range []string{secret1, secret2} {
+		tok, err := Encrypt("hello", secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		msg, err := mf.Decrypt(tok, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if msg != "hello" {
+			t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+		}
+	}
+	tok, err := Encrypt("hello", secret3, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := mf.Decrypt(tok, now, time.Minute); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := NewMultiFernet().Encrypt("hello", now); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestMultiFernetWithPrimary(t *testing.T) {
+	const (
+		oldSecret = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		newSecret = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	now := time.Now()
+	orig := NewMultiFernet(oldSecret)
+	oldTok, err := orig.Encrypt("old", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	rotated := orig.WithPrimary(newSecret)
+
+	// The original must be unchanged.
+	if len(orig.secrets) != 1 || orig.secrets[0] != oldSecret {
+		t.Fatalf("original was modified: %q", orig.secrets)
+	}
+
+	// The new one encrypts with the new primary...
+	newTok, err := rotated.Encrypt("new", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := Decrypt(newTok, newSecret, now, time.Minute); err != nil {
+		t.Fatalf("token not encrypted with new primary: %s", err)
+	}
+	if _, err := orig.Decrypt(newTok, now, time.Minute); err == nil {
+		t.Fatal("original should not decrypt tokens from the new primary")
+	}
+
+	// ...but still decrypts old tokens.
+	if msg, err := rotated.Decrypt(oldTok, now, time.Minute); err != nil || msg != "old" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "old")
+	}
+
+	// Rotating to an existing secret moves it to the front.
+	back := rotated.WithPrimary(oldSecret)
+	if want := []string{oldSecret, newSecret}; len(back.secrets) != 2 || back.secrets[0] != want[0] || back.secrets[1] != want[1] {
+		t.Fatalf("got secrets %q, want %q", back.secrets, want)
+	}
+}
+
+func TestEncryptForSecrets(t *testing.T) {
+	secrets := []string{
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+		"DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g=",
+	}
+	now := time.Now()
+	tokens, err := EncryptForSecrets("hello", secrets, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if len(tokens) != len(secrets) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(secrets))
+	}
+	for i, tok := range tokens {
+		for j, secret := range secrets {
+			msg, err := Decrypt(tok, secret, now, time.Minute)
+			if i != j {
+				if err == nil {
+					t.Fatalf("token %d was decrypted by secret %d", i, j)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if msg != "hello" {
+				t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+			}
+		}
+	}
+	if _, err := EncryptForSecrets("hello", []string{secrets[0], "bogus"}, now); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestMatchingSecrets(t *testing.T) {
+	const (
+		secret1 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret2 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	now := time.Now()
+	tok, err := Encrypt("hello", secret1, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	var tests = []struct {
+		secrets []string
+		want    []int
+	}{
+		{nil, nil},
+		{[]string{secret2}, nil},
+		{[]string{secret1}, []int{0}},
+		{[]string{secret2, secret1}, []int{1}},
+		{[]string{secret1, secret2, secret1}, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		got := MatchingSecrets(tok, tt.secrets, now, time.Minute)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchingSecrets(%q) = %v, want %v", tt.secrets, got, tt.want)
+		}
+	}
+	if got := MatchingSecrets(tok, []string{secret1}, now.Add(time.Hour), time.Minute); got != nil {
+		t.Errorf("expired token matched secrets %v", got)
+	}
+}
+
+func TestDecryptWhich(t *testing.T) {
+	secrets := []string{
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+		"DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g=",
+	}
+	now := time.Now()
+	for want, secret := range secrets {
+		tok, err := Encrypt("hello", secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		msg, i, err := DecryptWhich(tok, secrets, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if msg != "hello" || i != want {
+			t.Fatalf("got (%q, %d), want (%q, %d)", msg, i, "hello", want)
+		}
+		msg, fp, err := DecryptWithFingerprint(tok, secrets, now, time.Minute)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		wantFP, err := KeyFingerprint(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != "hello" || fp != wantFP {
+			t.Fatalf("got (%q, %q), want (%q, %q)", msg, fp, "hello", wantFP)
+		}
+	}
+	tok, err := Encrypt("hello", secrets[0], now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, i, err := DecryptWhich(tok, secrets[1:], now, time.Minute); err == nil || i != -1 {
+		t.Fatalf("got (%d, %v), want (-1, error)", i, err)
+	}
+	if _, fp, err := DecryptWithFingerprint(tok, secrets[1:], now, time.Minute); err == nil || fp != "" {
+		t.Fatalf("got (%q, %v), want (\"\", error)", fp, err)
+	}
+}
+
+func TestDecryptPolicies(t *testing.T) {
+	const (
+		newSecret = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		oldSecret = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	policies := []Policy{
+		{Secret: newSecret, TTL: time.Hour},
+		{Secret: oldSecret, TTL: 30 * 24 * time.Hour},
+	}
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	newTok, _ := Encrypt("new", newSecret, issued)
+	oldTok, _ := Encrypt("old", oldSecret, issued)
+	tests := []struct {
+		desc    string
+		token   string
+		age     time.Duration
+		want    string
+		wantErr error
+	}{
+		{"new key, fresh", newTok, time.Minute, "new", nil},
+		{"new key, past its TTL", newTok, 2 * time.Hour, "", ErrExpired},
+		{"old key, fresh", oldTok, time.Minute, "old", nil},
+		{"old key, past the new key's TTL", oldTok, 2 * time.Hour, "old", nil},
+		{"old key, past its TTL", oldTok, 31 * 24 * time.Hour, "", ErrExpired},
+	}
+	for _, tt := range tests {
+		msg, err := DecryptPolicies(tt.token, policies, issued.Add(tt.age))
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: got error %v, want %v", tt.desc, err, tt.wantErr)
+		}
+		if msg != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.desc, msg, tt.want)
+		}
+	}
+	other, _ := Encrypt("other", "DQM4LyAEaM0WaysBjQZY-aJViq4rBoDL5f95pXBoO1g=", issued)
+	if _, err := DecryptPolicies(other, policies, issued); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if _, err := DecryptPolicies(newTok, nil, issued); err == nil {
+		t.Fatal("expected an error for no policies")
+	}
+}
+
+func TestEncryptFanout(t *testing.T) {
+	recipients := map[string]string{
+		"alice": "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+		"bob":   "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+		"carol": "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E=",
+	}
+	now := time.Now()
+	const msg = "a message longer than a single block"
+	tokens, err := EncryptFanout(msg, recipients, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if len(tokens) != len(recipients) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(recipients))
+	}
+	for recipient, secret := range recipients {
+		got, err := Decrypt(tokens[recipient], secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: decrypt error: %s", recipient, err)
+		}
+		if got != msg {
+			t.Fatalf("%s: wrong message: got %q, want %q", recipient, got, msg)
+		}
+		for other, tok := range tokens {
+			if other != recipient {
+				if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+					t.Fatalf("%s decrypted %s's token", recipient, other)
+				}
+			}
+		}
+	}
+	recipients["dave"] = "bad"
+	if _, err := EncryptFanout(msg, recipients, now); err == nil || !strings.Contains(err.Error(), "dave") {
+		t.Fatalf("got error %v, want one naming the recipient", err)
+	}
+}
@@ -0,0 +1,43 @@
+package fernet
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrWrongNamespace is returned by DecryptNamespaced when a token lacks
+// the expected namespace prefix.
+var ErrWrongNamespace = errors.New("fernet: wrong namespace")
+
+// The separator between a namespace and a token. It is not in the
+// base64 alphabet, so it can never appear in the token itself.
+const namespaceSep = "."
+
+// EncryptNamespaced is like Encrypt but prefixes the token with
+// namespace and a period, e.g. "app1.gAAAA...", so that tokens can be
+// routed by application without decrypting them. The rest is a standard
+// token. The prefix is not authenticated: anyone can move a token to
+// another namespace, so give each namespace its own secret if that
+// matters, or bind the token to it with EncryptForHost.
+func EncryptNamespaced(msg, namespace, secret string, now time.Time) (string, error) {
+	if namespace == "" {
+		return "", errors.New("fernet: empty namespace")
+	}
+	tok, err := Encrypt(msg, secret, now)
+	if err != nil {
+		return "", err
+	}
+	return namespace + namespaceSep + tok, nil
+}
+
+// DecryptNamespaced decrypts a token created by EncryptNamespaced. It
+// returns ErrWrongNamespace, without decoding the token, unless the
+// token's prefix is exactly expectedPrefix. See Decrypt.
+func DecryptNamespaced(token, expectedPrefix, secret string, now time.Time, ttl time.Duration) (string, error) {
+	i := strings.LastIndex(token, namespaceSep)
+	if i < 0 || token[:i] != expectedPrefix {
+		return "", ErrWrongNamespace
+	}
+	return Decrypt(token[i+len(namespaceSep):], secret, now, ttl)
+}
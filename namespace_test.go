@@ -0,0 +1,50 @@
+package fernet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNamespaced(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, ns := range []string{"app1", "eu.app1"} {
+		tok, err := EncryptNamespaced("hello", ns, secret, now)
+		if err != nil {
+			t.Fatalf("%q: encrypt error: %s", ns, err)
+		}
+		if !strings.HasPrefix(tok, ns+".gAAAA") {
+			t.Fatalf("%q: got token %q", ns, tok)
+		}
+		msg, err := DecryptNamespaced(tok, ns, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%q: decrypt error: %s", ns, err)
+		}
+		if msg != "hello" {
+			t.Fatalf("%q: got %q, want %q", ns, msg, "hello")
+		}
+		// The rest is a standard token.
+		if _, err := Decrypt(strings.TrimPrefix(tok, ns+"."), secret, now, time.Minute); err != nil {
+			t.Fatalf("%q: decrypt error: %s", ns, err)
+		}
+		for _, wrong := range []string{"", "app2", "APP1", ns + ".", "x" + ns} {
+			if _, err := DecryptNamespaced(tok, wrong, secret, now, time.Minute); err != ErrWrongNamespace {
+				t.Errorf("%q, expecting %q: got error %v, want %v", ns, wrong, err, ErrWrongNamespace)
+			}
+		}
+	}
+	plain, err := Encrypt("hello", secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptNamespaced(plain, "app1", secret, now, time.Minute); err != ErrWrongNamespace {
+		t.Fatalf("got error %v for an unprefixed token, want %v", err, ErrWrongNamespace)
+	}
+	if _, err := DecryptNamespaced("app1."+plain, "app1", secret, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("expected an error for an expired token")
+	}
+	if _, err := EncryptNamespaced("hello", "", secret, now); err == nil {
+		t.Fatal("expected an error for an empty namespace")
+	}
+}
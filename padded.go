@@ -0,0 +1,66 @@
+package fernet
+
+import (
+	"errors"
+	"time"
+)
+
+// EncryptPadded is like Encrypt but hides the length of msg to within a
+// multiple of blockTarget bytes: all messages shorter than blockTarget
+// produce tokens of the same length, as do all messages whose length is
+// at least blockTarget but less than twice blockTarget, and so on.
+//
+// The message is first padded with a single 0x80 byte followed by as
+// many zero bytes as needed to reach a multiple of blockTarget (as in
+// ISO/IEC 7816-4), then encrypted as usual, including the PKCS #7
+// padding. DecryptPadded removes the extra padding by discarding the
+// trailing zeros and the 0x80 that precedes them, so it is unambiguous
+// for any message.
+//
+// blockTarget must be positive. Since the padded message is at least
+// blockTarget bytes long, a blockTarget greater than
+// DefaultMaxMessageSize, like a padded message longer than that, yields
+// ErrMessageTooLarge.
+//
+// This is an extension to the Fernet spec: the token has its own
+// version byte and must be decrypted with DecryptPadded.
+func EncryptPadded(msg, secret string, now time.Time, blockTarget int) (string, error) {
+	const maxInt = int(^uint(0) >> 1)
+	if blockTarget <= 0 {
+		return "", errors.New("fernet: block target must be positive")
+	}
+	if blockTarget > DefaultMaxMessageSize {
+		return "", ErrMessageTooLarge
+	}
+	blocks := len(msg)/blockTarget + 1
+	if blocks > maxInt/blockTarget {
+		return "", ErrMessageTooLarge
+	}
+	n := blockTarget * blocks
+	if err := checkMessageLen(n, 0); err != nil {
+		return "", err
+	}
+	padded := make([]byte, n)
+	copy(padded, msg)
+	padded[len(msg)] = 0x80
+	return seal(&format{version: versionPadded}, nil, string(padded), secret, now, randomIV)
+}
+
+// DecryptPadded decrypts a token created by EncryptPadded. See Decrypt.
+func DecryptPadded(token, secret string, now time.Time, ttl time.Duration) (string, error) {
+	padded, ts, _, err := open(&format{version: versionPadded}, 0, token, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != 0x80 {
+		return "", ErrInvalidPadding
+	}
+	return string(padded[:i]), nil
+}
@@ -0,0 +1,61 @@
+package fernet
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptPadded(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, target := range []int{1, 7, 16, 64, 100} {
+		lens := make(map[int]int) // token length by bucket
+		for n := 0; n < 3*target; n++ {
+			msg := strings.Repeat("\x00", n/2) + strings.Repeat("\x80", n-n/2)
+			tok, err := EncryptPadded(msg, secret, now, target)
+			if err != nil {
+				t.Fatalf("encrypt error: %s", err)
+			}
+			got, err := DecryptPadded(tok, secret, now, time.Minute)
+			if err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if got != msg {
+				t.Fatalf("target %d: wrong message: got %q, want %q", target, got, msg)
+			}
+			bucket := n / target
+			if l, ok := lens[bucket]; ok && l != len(tok) {
+				t.Fatalf("target %d: messages of length %d yield tokens of different lengths", target, n)
+			}
+			lens[bucket] = len(tok)
+			if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+				t.Fatal("standard Decrypt accepted the token")
+			}
+		}
+	}
+	if _, err := EncryptPadded("hello", secret, now, 0); err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, target := range []int{DefaultMaxMessageSize + 1, 1 << 30, math.MaxInt} {
+		if _, err := EncryptPadded("hello", secret, now, target); err != ErrMessageTooLarge {
+			t.Fatalf("target %d: got error %v, want %v", target, err, ErrMessageTooLarge)
+		}
+	}
+	tok, err := EncryptPadded("hello", secret, now, 16)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptPadded(tok, secret, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("expected an expiry error")
+	}
+	// A token from the same version without the extra padding.
+	tok, err = seal(&format{version: versionPadded}, nil, "hello", secret, now, randomIV)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptPadded(tok, secret, now, time.Minute); err == nil {
+		t.Fatal("expected a padding error")
+	}
+}
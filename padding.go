@@ -0,0 +1,63 @@
+package fernet
+
+import "time"
+
+// A Padding is a block padding scheme, which extends a message to a
+// whole number of AES blocks before it is encrypted. Implementations
+// must be safe for concurrent use.
+type Padding interface {
+	// Pad appends src, padded, to dst and returns the extended slice.
+	// The padded message must be a nonzero multiple of 16 bytes long.
+	Pad(dst, src []byte) []byte
+
+	// Unpad reverses Pad, returning a slice of src, or nil if src is
+	// not correctly padded.
+	Unpad(src []byte) []byte
+}
+
+// PKCS7Padding is the PKCS #7 padding used by standard Fernet tokens.
+type PKCS7Padding struct{}
+
+// Pad implements Padding.
+func (PKCS7Padding) Pad(dst, src []byte) []byte {
+	n := len(dst)
+	dst = append(dst, make([]byte, paddedLen(len(src)))...)
+	pad(dst[n:], src)
+	return dst
+}
+
+// Unpad implements Padding. It takes the same time regardless of the
+// padding's value.
+func (PKCS7Padding) Unpad(src []byte) []byte {
+	return unpad(src)
+}
+
+// EncryptWithPadding is like Encrypt but pads the message with p, or
+// with PKCS7Padding if p is nil, e.g. to interoperate with an
+// implementation that uses a different scheme. This is an extension to
+// the Fernet spec: the token has its own version byte and must be
+// decrypted with DecryptWithPadding and the same padding. The token
+// does not identify the padding.
+func EncryptWithPadding(msg, secret string, now time.Time, p Padding) (string, error) {
+	if p == nil {
+		p = PKCS7Padding{}
+	}
+	return seal(&format{version: versionPadding, padding: p}, nil, msg, secret, now, randomIV)
+}
+
+// DecryptWithPadding decrypts a token created by EncryptWithPadding,
+// removing the padding with p, or with PKCS7Padding if p is nil. See
+// Decrypt.
+func DecryptWithPadding(token, secret string, now time.Time, ttl time.Duration, p Padding) (string, error) {
+	if p == nil {
+		p = PKCS7Padding{}
+	}
+	msg, ts, _, err := open(&format{version: versionPadding, padding: p}, 0, token, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
@@ -0,0 +1,88 @@
+package fernet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ISO/IEC 7816-4 padding: a 0x80 byte followed by zeros.
+type isoPadding struct{}
+
+func (isoPadding) Pad(dst, src []byte) []byte {
+	dst = append(append(dst, src...), 0x80)
+	for len(dst)%16 != 0 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func (isoPadding) Unpad(src []byte) []byte {
+	i := bytes.LastIndexFunc(src, func(r rune) bool { return r != 0 })
+	if i < 0 || src[i] != 0x80 {
+		return nil
+	}
+	return src[:i]
+}
+
+// A broken padding that does not fill the last block.
+type shortPadding struct{}
+
+func (shortPadding) Pad(dst, src []byte) []byte { return append(dst, src...) }
+func (shortPadding) Unpad(src []byte) []byte    { return src }
+
+func TestEncryptWithPadding(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, p := range []Padding{nil, PKCS7Padding{}, isoPadding{}} {
+		for n := 0; n < 40; n++ {
+			msg := strings.Repeat("\x80", n)
+			tok, err := EncryptWithPadding(msg, secret, now, p)
+			if err != nil {
+				t.Fatalf("%T: encrypt error: %s", p, err)
+			}
+			got, err := DecryptWithPadding(tok, secret, now, time.Minute, p)
+			if err != nil {
+				t.Fatalf("%T: decrypt error: %s", p, err)
+			}
+			if got != msg {
+				t.Fatalf("%T: wrong message: got %q, want %q", p, got, msg)
+			}
+			if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+				t.Fatalf("%T: standard Decrypt accepted the token", p)
+			}
+		}
+	}
+	tok, err := EncryptWithPadding("hello", secret, now, isoPadding{})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptWithPadding(tok, secret, now, time.Minute, nil); err != ErrInvalidPadding {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidPadding)
+	}
+	if _, err := DecryptWithPadding(tok, secret, now.Add(time.Hour), time.Minute, isoPadding{}); err == nil {
+		t.Fatal("expected an expiry error")
+	}
+	if _, err := EncryptWithPadding("hello", secret, now, shortPadding{}); err == nil {
+		t.Fatal("expected an error for a partial block")
+	}
+}
+
+func TestPKCS7Padding(t *testing.T) {
+	prefix := []byte("prefix")
+	for n := 0; n < 40; n++ {
+		msg := bytes.Repeat([]byte{'a'}, n)
+		got := PKCS7Padding{}.Pad(append([]byte(nil), prefix...), msg)
+		if !bytes.HasPrefix(got, prefix) {
+			t.Fatalf("n=%d: Pad overwrote dst", n)
+		}
+		padded := got[len(prefix):]
+		if len(padded) != paddedLen(n) {
+			t.Fatalf("n=%d: got %d bytes, want %d", n, len(padded), paddedLen(n))
+		}
+		if !bytes.Equal(PKCS7Padding{}.Unpad(padded), msg) {
+			t.Fatalf("n=%d: Unpad did not reverse Pad", n)
+		}
+	}
+}
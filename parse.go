@@ -0,0 +1,251 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Token holds the fields of a token. Because ParseToken does not verify
+// the token's signature, none of the fields can be trusted.
+type Token struct {
+	Version    byte
+	Timestamp  time.Time
+	IV         []byte
+	Ciphertext []byte
+	HMAC       []byte
+}
+
+// ParseToken decodes token and splits it into its fields. It does not
+// require the secret, so it cannot verify the token; use it only for
+// diagnostics, never to make decisions about authentication.
+func ParseToken(token string) (*Token, error) {
+	tok, err := decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	n := len(tok)
+	if (n-fixedLen)%aes.BlockSize != 0 {
+		return nil, ErrCiphertextLength
+	}
+	return &Token{
+		Version:    tok[0],
+		Timestamp:  time.Unix(int64(binary.BigEndian.Uint64(tok[tsOffset:])), 0),
+		IV:         tok[ivOffset:msgOffset],
+		Ciphertext: tok[msgOffset : n-sha256.Size],
+		HMAC:       tok[n-sha256.Size:],
+	}, nil
+}
+
+// InspectJSON parses token with ParseToken and returns its fields as a
+// JSON object, for tools that display tokens, such as a debugging
+// endpoint:
+//
+//	{
+//	  "version": 128,
+//	  "timestamp": "1985-10-26T08:20:00Z",
+//	  "iv": "000102030405060708090a0b0c0d0e0f",
+//	  "ciphertext_len": 16,
+//	  "hmac": "c5ff9095...2a0c"
+//	}
+//
+// The timestamp is in UTC and the IV and HMAC are hex-encoded. Since no
+// secret is involved, none of the fields is verified and the object
+// holds nothing that needs protecting beyond the token itself.
+func InspectJSON(token string) ([]byte, error) {
+	t, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Version       byte   `json:"version"`
+		Timestamp     string `json:"timestamp"`
+		IV            string `json:"iv"`
+		CiphertextLen int    `json:"ciphertext_len"`
+		HMAC          string `json:"hmac"`
+	}{
+		Version:       t.Version,
+		Timestamp:     t.Timestamp.UTC().Format(time.RFC3339),
+		IV:            hex.EncodeToString(t.IV),
+		CiphertextLen: len(t.Ciphertext),
+		HMAC:          hex.EncodeToString(t.HMAC),
+	})
+}
+
+// TokenTimestampBytes returns the timestamp field of token exactly as
+// stored: a 64-bit big-endian count of seconds since the Unix epoch.
+// This helps diagnose byte-order problems in other implementations; use
+// ParseToken to get the timestamp as a time.Time. Like ParseToken, it
+// checks only the token's length and version, so the timestamp cannot
+// be trusted.
+func TokenTimestampBytes(token string) ([8]byte, error) {
+	var ts [8]byte
+	tok, err := decodeToken(token)
+	if err != nil {
+		return ts, err
+	}
+	copy(ts[:], tok[tsOffset:tsOffset+tsLen])
+	return ts, nil
+}
+
+// DetectIVReuse reports every pair of tokens that share the same IV,
+// which should never happen unless the random number generator used to
+// create them was broken. Each pair holds two indices into tokens, the
+// smaller first. Because IVs are not encrypted, no secret is needed.
+// This is a diagnostic for auditing old tokens; it cannot repair them.
+func DetectIVReuse(tokens []string) ([][2]int, error) {
+	var (
+		pairs [][2]int
+		seen  = make(map[[aes.BlockSize]byte][]int)
+	)
+	for i, token := range tokens {
+		t, err := ParseToken(token)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", i, err)
+		}
+		var iv [aes.BlockSize]byte
+		copy(iv[:], t.IV)
+		for _, j := range seen[iv] {
+			pairs = append(pairs, [2]int{j, i})
+		}
+		seen[iv] = append(seen[iv], i)
+	}
+	return pairs, nil
+}
+
+// AgeHistogram tallies the ages of tokens, as of now, for monitoring.
+// buckets holds the upper bounds of the histogram's buckets in
+// increasing order: counts[i] is the number of tokens whose age is at
+// most buckets[i] but greater than buckets[i-1], and the extra last
+// count is the number older than every bound. Tokens issued in the
+// future count towards the first bucket.
+//
+// Since no secret is needed, the timestamps are not verified, which is
+// fine for a rough view of how stale tokens are. Malformed tokens are
+// skipped, so the counts add up to less than len(tokens) if there were
+// any.
+func AgeHistogram(tokens []string, now time.Time, buckets []time.Duration) ([]int, error) {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return nil, errors.New("fernet: histogram buckets must be in increasing order")
+		}
+	}
+	counts := make([]int, len(buckets)+1)
+	for _, token := range tokens {
+		t, err := ParseToken(token)
+		if err != nil {
+			continue
+		}
+		age := now.Sub(t.Timestamp)
+		i := sort.Search(len(buckets), func(i int) bool { return age <= buckets[i] })
+		counts[i]++
+	}
+	return counts, nil
+}
+
+// Base64-decodes token and checks its length and version.
+func decodeToken(token string) ([]byte, error) {
+	// Reject tokens with the wrong version before decoding, since that
+	// allocates, so garbage input costs as little as possible. The
+	// decoder ignores newlines, so a token starting with one must take
+	// the slow path, as must one that is not base64 at all, so that it
+	// is reported as such.
+	if len(token) > 0 && token[0] != versionPrefix && isBase64URLChar(token[0]) {
+		if len(token) < minEncodedLen {
+			return nil, ErrTokenTooShort
+		}
+		return nil, ErrWrongVersion
+	}
+	tok, err := decodeTokenAnyVersion(token)
+	if err != nil {
+		return nil, err
+	}
+	if tok[0] != version {
+		return nil, ErrWrongVersion
+	}
+	return tok, nil
+}
+
+// Reports whether c is in the URL-safe base64 alphabet.
+func isBase64URLChar(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_'
+}
+
+// The first character of every encoded token with the spec's version,
+// and the length of the shortest possible encoded token.
+var (
+	versionPrefix = base64.URLEncoding.EncodeToString([]byte{version})[0]
+	minEncodedLen = base64.URLEncoding.EncodedLen(fixedLen + aes.BlockSize)
+)
+
+// Like decodeToken but does not check the version.
+func decodeTokenAnyVersion(token string) ([]byte, error) {
+	// Newlines are ignored by the decoder but never shorten a token.
+	if len(token) < minEncodedLen {
+		return nil, ErrTokenTooShort
+	}
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	// To simplify bounds checking, make sure we have enough data.
+	if minLen := fixedLen + aes.BlockSize; len(tok) < minLen {
+		return nil, ErrTokenTooShort
+	}
+	return tok, nil
+}
+
+// DecodeTokenBytes returns the raw bytes of token, which take up a
+// quarter less space than its base64 encoding. The token's length and
+// version are checked but its signature is not. See EncodeTokenBytes.
+func DecodeTokenBytes(token string) ([]byte, error) {
+	return decodeToken(token)
+}
+
+// EncodeTokenBytes is the inverse of DecodeTokenBytes.
+func EncodeTokenBytes(raw []byte) (string, error) {
+	if minLen := fixedLen + aes.BlockSize; len(raw) < minLen {
+		return "", ErrTokenTooShort
+	}
+	if raw[0] != version {
+		return "", ErrWrongVersion
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+// ReencodeToken decodes token using from and re-encodes it using to,
+// e.g. to migrate tokens stored with the standard base64 alphabet to the
+// URL-safe one that Decrypt requires. The token's length and version are
+// checked but its signature is not; since the bytes are unchanged, the
+// token remains exactly as valid as it was.
+func ReencodeToken(token string, from, to *base64.Encoding) (string, error) {
+	raw, err := from.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	if minLen := fixedLen + aes.BlockSize; len(raw) < minLen {
+		return "", ErrTokenTooShort
+	}
+	if raw[0] != version {
+		return "", ErrWrongVersion
+	}
+	return to.EncodeToString(raw), nil
+}
+
+// SigningInput returns the portion of token covered by its HMAC, which
+// is everything but the final 32 bytes. It does not require the secret
+// and is intended for verifying tokens with external tools.
+func SigningInput(token string) ([]byte, error) {
+	tok, err := decodeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return tok[:len(tok)-sha256.Size], nil
+}
@@ -0,0 +1,253 @@
+package fernet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseToken(t *testing.T) {
+	const token = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	tok, err := ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Version != 0x80 {
+		t.Errorf("wrong version: %#x", tok.Version)
+	}
+	if want := time.Date(1985, time.October, 26, 8, 20, 0, 0, time.UTC); !tok.Timestamp.Equal(want) {
+		t.Errorf("wrong timestamp: got %s, want %s", tok.Timestamp, want)
+	}
+	if want := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}; !bytes.Equal(tok.IV, want) {
+		t.Errorf("wrong IV: got %v, want %v", tok.IV, want)
+	}
+	if len(tok.Ciphertext) != 16 || len(tok.HMAC) != 32 {
+		t.Errorf("wrong lengths: ciphertext %d, HMAC %d", len(tok.Ciphertext), len(tok.HMAC))
+	}
+	for _, bad := range []string{
+		"gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA==",
+		"%%%%%%%%%%%%%AECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPAl1-szkFVzXTuGb4hR8AKtwcaX1YdykRtfsH-p1YsUD2Q==",
+		"gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPOm73QeoCk9uGib28Xe5vz6oxq5nmxbx_v7mrfyudzUm",
+	} {
+		if _, err := ParseToken(bad); err == nil {
+			t.Errorf("ParseToken(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestInspectJSON(t *testing.T) {
+	const token = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	b, err := InspectJSON(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %s", b, err)
+	}
+	want := map[string]interface{}{
+		"version":        float64(0x80),
+		"timestamp":      "1985-10-26T08:20:00Z",
+		"iv":             "000102030405060708090a0b0c0d0e0f",
+		"ciphertext_len": float64(16),
+		"hmac":           "c5ff9095f5d38f9ab86e5543e02686f03b3ec971b9ab47ae23566a54e08c2a0c",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %s, want %v", b, want)
+	}
+	if _, err := InspectJSON("bogus"); err == nil {
+		t.Fatal("expected an error for an invalid token")
+	}
+}
+
+func TestTokenTimestampBytes(t *testing.T) {
+	const token = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	ts, err := TokenTimestampBytes(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1985-10-26 08:20:00 UTC is 499162800 seconds after the epoch.
+	if want := [8]byte{0, 0, 0, 0, 0x1d, 0xc0, 0x9e, 0xb0}; ts != want {
+		t.Fatalf("got % x, want % x", ts, want)
+	}
+	for _, bad := range []string{"", "garbage", token[:40]} {
+		if _, err := TokenTimestampBytes(bad); err == nil {
+			t.Errorf("TokenTimestampBytes(%q): expected an error", bad)
+		}
+	}
+	raw, _ := base64.URLEncoding.DecodeString(token)
+	raw[0] = 0x81
+	if _, err := TokenTimestampBytes(base64.URLEncoding.EncodeToString(raw)); err != ErrWrongVersion {
+		t.Fatalf("got error %v, want %v", err, ErrWrongVersion)
+	}
+}
+
+func TestDetectIVReuse(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	fixedIV := func(b byte) func([]byte) error {
+		return func(p []byte) error {
+			for i := 0; i < 16; i++ {
+				p[i] = b
+			}
+			return nil
+		}
+	}
+	var tokens []string
+	for _, b := range []byte{1, 2, 1, 3, 2, 1} {
+		tok, err := encrypt("hello", secret, &EncryptOptions{Now: time.Now()}, fixedIV(b))
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		tokens = append(tokens, tok)
+	}
+	pairs, err := DetectIVReuse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]int{{0, 2}, {1, 4}, {0, 5}, {2, 5}}; !reflect.DeepEqual(pairs, want) {
+		t.Fatalf("got %v, want %v", pairs, want)
+	}
+	if _, err := DetectIVReuse(append(tokens, "garbage")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestAgeHistogram(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	var tokens []string
+	for _, age := range []time.Duration{
+		-time.Minute, 0, 30 * time.Second, time.Minute, // first bucket
+		time.Minute + time.Second, 59 * time.Minute, // second bucket
+		2 * time.Hour, 48 * time.Hour, // older
+	} {
+		tok, err := Encrypt("hello", secret, now.Add(-age))
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		tokens = append(tokens, tok)
+	}
+	tokens = append(tokens, "garbage", "")
+	counts, err := AgeHistogram(tokens, now, []time.Duration{time.Minute, time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{4, 2, 2}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("got %v, want %v", counts, want)
+	}
+	counts, err = AgeHistogram(tokens, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{8}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("got %v, want %v", counts, want)
+	}
+	if _, err := AgeHistogram(tokens, now, []time.Duration{time.Hour, time.Minute}); err == nil {
+		t.Fatal("expected an error for unsorted buckets")
+	}
+}
+
+func TestTokenBytes(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	for _, msg := range []string{"", "hello", "0123456789abcdef", "a longer message spanning several blocks"} {
+		tok, err := Encrypt(msg, secret, time.Now())
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		raw, err := DecodeTokenBytes(tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := len(tok) * 3 / 4; len(raw) > want {
+			t.Fatalf("raw token is %d bytes, want at most %d", len(raw), want)
+		}
+		got, err := EncodeTokenBytes(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tok {
+			t.Fatalf("round trip failed: got %q, want %q", got, tok)
+		}
+	}
+	if _, err := DecodeTokenBytes("gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA=="); err == nil {
+		t.Fatal("expected an error for a short token")
+	}
+	if _, err := EncodeTokenBytes(make([]byte, fixedLen+16)); err == nil {
+		t.Fatal("expected an error for the wrong version")
+	}
+	if _, err := EncodeTokenBytes([]byte{0x80}); err == nil {
+		t.Fatal("expected an error for a short token")
+	}
+}
+
+func TestReencodeToken(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	// Try enough messages that some tokens contain '-' or '_'.
+	for i := 0; i < 20; i++ {
+		msg := strings.Repeat("?", i)
+		tok, err := Encrypt(msg, secret, now)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		std, err := ReencodeToken(tok, base64.URLEncoding, base64.StdEncoding)
+		if err != nil {
+			t.Fatalf("reencode error: %s", err)
+		}
+		if strings.ContainsAny(std, "-_") {
+			t.Fatalf("%q is not standard base64", std)
+		}
+		back, err := ReencodeToken(std, base64.StdEncoding, base64.URLEncoding)
+		if err != nil {
+			t.Fatalf("reencode error: %s", err)
+		}
+		if back != tok {
+			t.Fatalf("round trip failed: got %q, want %q", back, tok)
+		}
+		if got, err := Decrypt(back, secret, now, time.Minute); err != nil || got != msg {
+			t.Fatalf("got (%q, %v), want (%q, nil)", got, err, msg)
+		}
+	}
+	if _, err := ReencodeToken("not base64!", base64.URLEncoding, base64.StdEncoding); err == nil {
+		t.Fatal("expected an error for invalid base64")
+	}
+	if _, err := ReencodeToken("gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA==", base64.URLEncoding, base64.StdEncoding); err == nil {
+		t.Fatal("expected an error for a short token")
+	}
+	if _, err := ReencodeToken(base64.URLEncoding.EncodeToString(make([]byte, fixedLen+16)), base64.URLEncoding, base64.StdEncoding); err == nil {
+		t.Fatal("expected an error for the wrong version")
+	}
+}
+
+func TestSigningInput(t *testing.T) {
+	const (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	input, err := SigningInput(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, _, err := extractKeys(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := hmac.New(sha256.New, signingKey)
+	hash.Write(input)
+	if !hmac.Equal(hash.Sum(nil), tok.HMAC) {
+		t.Fatal("HMAC of signing input does not match token")
+	}
+	if _, err := SigningInput("gAAAAAAdwJ6xAAECAwQFBgcICQoLDA0OD3HkMATM5lFqGaerZ-fWPA=="); err == nil {
+		t.Fatal("expected an error")
+	}
+}
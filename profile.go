@@ -0,0 +1,77 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// A Profile identifies the format of a token: the standard Fernet
+// format or one of the extensions implemented by this package. Its
+// value is the token's version byte.
+type Profile byte
+
+// The known token profiles, with the functions that decrypt them.
+const (
+	ProfileStandard Profile = version         // Decrypt
+	ProfileSelfTTL  Profile = versionSelfTTL  // DecryptSelfTTL
+	ProfileKeyID    Profile = versionKeyID    // KeyRing.Decrypt
+	ProfileMAC      Profile = versionMAC      // DecryptWithMAC
+	ProfilePadded   Profile = versionPadded   // DecryptPadded
+	ProfileHost     Profile = versionHost     // DecryptForHost
+	ProfileTimeless Profile = versionTimeless // DecryptTimeless
+	ProfilePadding  Profile = versionPadding  // DecryptWithPadding
+	ProfileAES256   Profile = versionAES256   // Decrypt256
+	ProfileSigned   Profile = versionSigned   // Verify
+	ProfileDerived  Profile = versionDerived  // DecryptDerived
+	ProfileSplit    Profile = versionSplit    // DecryptJoin
+	ProfileBundle   Profile = versionBundle   // ImportKeyRing
+)
+
+var profileNames = map[Profile]string{
+	ProfileStandard: "standard",
+	ProfileSelfTTL:  "self-TTL",
+	ProfileKeyID:    "key ID",
+	ProfileMAC:      "custom MAC",
+	ProfilePadded:   "padded",
+	ProfileHost:     "host-bound",
+	ProfileTimeless: "timeless",
+	ProfilePadding:  "custom padding",
+	ProfileAES256:   "AES-256",
+	ProfileSigned:   "sign-only",
+	ProfileDerived:  "derived keys",
+	ProfileSplit:    "split message",
+	ProfileBundle:   "key ring bundle",
+}
+
+func (p Profile) String() string {
+	if name, ok := profileNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Profile(%#x)", byte(p))
+}
+
+// ErrUnknownProfile is returned by TokenProfile for a token whose
+// version byte is not that of a known profile.
+var ErrUnknownProfile = errors.New("fernet: unknown token profile")
+
+// TokenProfile returns the profile of token, as given by its version
+// byte, so that it can be passed to the right decryption function. Only
+// the version byte is examined: the token is not verified, and it may
+// turn out to be malformed. Chunked streams are not tokens, so they
+// have no profile.
+func TokenProfile(token string) (Profile, error) {
+	// The first four characters encode the version byte.
+	if len(token) < 4 {
+		return 0, ErrTokenTooShort
+	}
+	b, err := base64.URLEncoding.DecodeString(token[:4])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	p := Profile(b[0])
+	if _, ok := profileNames[p]; !ok {
+		return 0, ErrUnknownProfile
+	}
+	return p, nil
+}
@@ -0,0 +1,78 @@
+package fernet
+
+import (
+	"crypto/sha512"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenProfile(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	secret256, _ := RandomSecret256()
+	kr := NewKeyRing()
+	if err := kr.Add("k1", secret); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		want    Profile
+		encrypt func() (string, error)
+	}{
+		{ProfileStandard, func() (string, error) { return Encrypt("hello", secret, now) }},
+		{ProfileSelfTTL, func() (string, error) { return EncryptWithTTL("hello", secret, now, time.Minute) }},
+		{ProfileKeyID, func() (string, error) { return kr.EncryptWithID("hello", now) }},
+		{ProfileMAC, func() (string, error) { return EncryptWithMAC("hello", secret, now, sha512.New) }},
+		{ProfilePadded, func() (string, error) { return EncryptPadded("hello", secret, now, 32) }},
+		{ProfileHost, func() (string, error) { return EncryptForHost("hello", "example.com", secret, now) }},
+		{ProfileTimeless, func() (string, error) { return EncryptTimeless("hello", secret) }},
+		{ProfilePadding, func() (string, error) { return EncryptWithPadding("hello", secret, now, nil) }},
+		{ProfileAES256, func() (string, error) { return Encrypt256("hello", secret256, now) }},
+		{ProfileSigned, func() (string, error) { return Sign("hello", secret, now) }},
+		{ProfileDerived, func() (string, error) { return EncryptDerived("hello", secret, now) }},
+		{ProfileSplit, func() (string, error) {
+			tokens, err := EncryptSplit("hello", secret, now, 16)
+			if err != nil {
+				return "", err
+			}
+			return tokens[0], nil
+		}},
+		{ProfileBundle, func() (string, error) { return ExportKeyRing([]string{secret}, secret, now) }},
+	}
+	seen := make(map[Profile]bool)
+	for _, tt := range tests {
+		tok, err := tt.encrypt()
+		if err != nil {
+			t.Fatalf("%s: encrypt error: %s", tt.want, err)
+		}
+		got, err := TokenProfile(tok)
+		if err != nil {
+			t.Fatalf("%s: TokenProfile error: %s", tt.want, err)
+		}
+		if got != tt.want {
+			t.Fatalf("got profile %s, want %s", got, tt.want)
+		}
+		seen[got] = true
+	}
+	if len(seen) != len(profileNames) {
+		t.Fatalf("tested %d profiles, but %d are known", len(seen), len(profileNames))
+	}
+
+	for _, tt := range []struct {
+		token string
+		want  error
+	}{
+		{"", ErrTokenTooShort},
+		{"gAA", ErrTokenTooShort},
+		{"%%%%AAAA", ErrTokenNotBase64},
+		{"AAAAAAAA", ErrUnknownProfile}, // version 0x00
+		{"_____AAA", ErrUnknownProfile}, // version 0xff
+	} {
+		if _, err := TokenProfile(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("TokenProfile(%q): got error %v, want %v", tt.token, err, tt.want)
+		}
+	}
+	if got, want := Profile(0xff).String(), "Profile(0xff)"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package fernet
+
+import "time"
+
+// A SecretProvider supplies the secrets used by a Fernet. Because a
+// Fernet consults its provider on every call, the provider is free to
+// change its secrets at any time, e.g. in response to an external key
+// rotation. Implementations must be safe for concurrent use.
+type SecretProvider interface {
+	// Current returns the secret used for encryption.
+	Current() string
+
+	// All returns the secrets that may be used for decryption, in the
+	// order they should be tried. Usually the first is Current().
+	All() []string
+}
+
+// StaticSecretProvider is a SecretProvider whose secrets never change.
+// The first secret is the current one.
+type StaticSecretProvider []string
+
+// Current implements SecretProvider.
+func (p StaticSecretProvider) Current() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[0]
+}
+
+// All implements SecretProvider.
+func (p StaticSecretProvider) All() []string {
+	return p
+}
+
+// Fernet encrypts and decrypts tokens using the secrets supplied by a
+// SecretProvider. It is safe for concurrent use if its provider is.
+type Fernet struct {
+	provider SecretProvider
+	stats    opStats
+}
+
+// NewFernetWithProvider returns a Fernet that fetches its secrets from
+// p each time it encrypts or decrypts a token.
+func NewFernetWithProvider(p SecretProvider) *Fernet {
+	return &Fernet{provider: p}
+}
+
+// Encrypt encrypts msg with the provider's current secret. See Encrypt.
+func (f *Fernet) Encrypt(msg string, now time.Time) (string, error) {
+	f.stats.encrypts.Add(1)
+	return Encrypt(msg, f.provider.Current(), now)
+}
+
+// Decrypt tries each of the provider's secrets in order, returning the
+// message from the first one that successfully decrypts token. See
+// Decrypt.
+func (f *Fernet) Decrypt(token string, now time.Time, ttl time.Duration) (string, error) {
+	msg, err := decryptAny(token, f.provider.All(), now, ttl)
+	f.stats.decrypted(err)
+	return msg, err
+}
+
+// Stats returns the number of calls to Encrypt and Decrypt so far, and
+// how many of the calls to Decrypt failed, e.g. for alerting on a spike
+// in invalid tokens. The counts are read separately, so while other
+// goroutines are using f they may not be mutually consistent.
+func (f *Fernet) Stats() (encrypts, decrypts, failures uint64) {
+	return f.stats.get()
+}
+
+// NewFernet returns a Fernet that always encrypts with primary and
+// decrypts by trying primary and then each of the secrets returned by
+// fallbacks.All, which is consulted on every call. This models a single
+// current secret plus a set of retiring secrets fetched from a store.
+// fallbacks may be nil.
+func NewFernet(primary string, fallbacks SecretProvider) *Fernet {
+	return &Fernet{provider: primaryProvider{primary, fallbacks}}
+}
+
+// A SecretProvider with a fixed current secret and dynamic fallbacks.
+type primaryProvider struct {
+	primary   string
+	fallbacks SecretProvider
+}
+
+func (p primaryProvider) Current() string {
+	return p.primary
+}
+
+func (p primaryProvider) All() []string {
+	if p.fallbacks == nil {
+		return []string{p.primary}
+	}
+	// Build a new slice so the provider's own is never modified.
+	fallbacks := p.fallbacks.All()
+	all := make([]string, 0, 1+len(fallbacks))
+	all = append(all, p.primary)
+	for _, s := range fallbacks {
+		if s != p.primary {
+			all = append(all, s)
+		}
+	}
+	return all
+}
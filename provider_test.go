@@ -0,0 +1,137 @@
+package fernet
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// A SecretProvider whose secrets can be replaced at any time.
+type swappableProvider struct {
+	mu      sync.Mutex
+	secrets []string
+}
+
+func (p *swappableProvider) set(secrets ...string) {
+	p.mu.Lock()
+	p.secrets = secrets
+	p.mu.Unlock()
+}
+
+func (p *swappableProvider) Current() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.secrets[0]
+}
+
+func (p *swappableProvider) All() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.secrets
+}
+
+func TestFernetWithProvider(t *testing.T) {
+	const (
+		secret1 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret2 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	now := time.Now()
+	p := &swappableProvider{secrets: []string{secret1}}
+	f := NewFernetWithProvider(p)
+	tok1, err := f.Encrypt("one", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+
+	// Rotate: secret2 becomes current, secret1 is retained.
+	p.set(secret2, secret1)
+	tok2, err := f.Encrypt("two", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := Decrypt(tok2, secret2, now, time.Minute); err != nil {
+		t.Fatalf("token not encrypted with the new secret: %s", err)
+	}
+	for tok, want := range map[string]string{tok1: "one", tok2: "two"} {
+		if msg, err := f.Decrypt(tok, now, time.Minute); err != nil || msg != want {
+			t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, want)
+		}
+	}
+
+	// Retire secret1.
+	p.set(secret2)
+	if _, err := f.Decrypt(tok1, now, time.Minute); err == nil {
+		t.Fatal("expected an error after secret was retired")
+	}
+}
+
+func TestStaticSecretProvider(t *testing.T) {
+	const secret = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+	now := time.Now()
+	f := NewFernetWithProvider(StaticSecretProvider{secret})
+	tok, err := f.Encrypt("hello", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := f.Decrypt(tok, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+	if _, err := NewFernetWithProvider(StaticSecretProvider{}).Encrypt("hello", now); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestMultiFernetIsSecretProvider(t *testing.T) {
+	var _ SecretProvider = NewMultiFernet()
+}
+
+func TestNewFernet(t *testing.T) {
+	const (
+		primary = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		secret1 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret2 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	now := time.Now()
+	tok1, _ := Encrypt("one", secret1, now)
+	tok2, _ := Encrypt("two", secret2, now)
+	p := &swappableProvider{secrets: []string{secret1}}
+	f := NewFernet(primary, p)
+
+	tok, err := f.Encrypt("hello", now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := Decrypt(tok, primary, now, time.Minute); err != nil {
+		t.Fatalf("token not encrypted with the primary secret: %s", err)
+	}
+	if msg, err := f.Decrypt(tok, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+	if msg, err := f.Decrypt(tok1, now, time.Minute); err != nil || msg != "one" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "one")
+	}
+	if _, err := f.Decrypt(tok2, now, time.Minute); err == nil {
+		t.Fatal("decrypted a token from an unknown secret")
+	}
+
+	// Change the fallbacks; the primary is unaffected.
+	p.set(secret2)
+	if _, err := f.Decrypt(tok1, now, time.Minute); err == nil {
+		t.Fatal("decrypted a token from a retired secret")
+	}
+	if msg, err := f.Decrypt(tok2, now, time.Minute); err != nil || msg != "two" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "two")
+	}
+	if msg, err := f.Decrypt(tok, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+
+	// Without a provider, only the primary is used.
+	f = NewFernet(primary, nil)
+	if msg, err := f.Decrypt(tok, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+	if _, err := f.Decrypt(tok1, now, time.Minute); err == nil {
+		t.Fatal("decrypted a token from an unknown secret")
+	}
+}
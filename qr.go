@@ -0,0 +1,36 @@
+package fernet
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// The encoding used by EncryptQR. Every character of unpadded base32 is
+// in the QR code alphanumeric character set, which packs 5.5 bits into
+// each character, whereas base64's mixed case forces byte mode at 8.
+var qrEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EncryptQR is like Encrypt but encodes the token in upper-case base32
+// without padding rather than URL-safe base64, so that it can be stored
+// in a QR code in alphanumeric mode, which needs fewer modules despite
+// the longer string. The token's bytes are exactly those of a standard
+// token; only the encoding differs. Use DecryptQR to decrypt it.
+func EncryptQR(msg, secret string, now time.Time) (string, error) {
+	token, err := Encrypt(msg, secret, now)
+	if err != nil {
+		return "", err
+	}
+	tok, _ := base64.URLEncoding.DecodeString(token)
+	return qrEncoding.EncodeToString(tok), nil
+}
+
+// DecryptQR decrypts a token created by EncryptQR. See Decrypt.
+func DecryptQR(token, secret string, now time.Time, ttl time.Duration) (string, error) {
+	tok, err := qrEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTokenNotBase64, err)
+	}
+	return Decrypt(base64.URLEncoding.EncodeToString(tok), secret, now, ttl)
+}
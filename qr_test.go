@@ -0,0 +1,57 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQR(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	)
+	now := time.Date(1985, time.October, 26, 8, 20, 1, 0, time.UTC)
+
+	// The spec's token, re-encoded, decrypts to the same message.
+	tok, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qr := qrEncoding.EncodeToString(tok)
+	msg, err := DecryptQR(qr, secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("got %q, want %q", msg, "hello")
+	}
+
+	// A new token uses only QR alphanumeric characters and holds the
+	// bytes of a standard token.
+	qr, err = EncryptQR("hello", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if i := strings.IndexFunc(qr, func(r rune) bool {
+		return !strings.ContainsRune("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:", r)
+	}); i >= 0 {
+		t.Fatalf("token %q has a character at %d outside the QR alphanumeric set", qr, i)
+	}
+	raw, err := qrEncoding.DecodeString(qr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := Decrypt(base64.URLEncoding.EncodeToString(raw), secret, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+
+	if _, err := DecryptQR(token, secret, now, time.Minute); !errors.Is(err, ErrTokenNotBase64) {
+		t.Fatalf("got error %v for a base64 token, want %v", err, ErrTokenNotBase64)
+	}
+	if _, err := EncryptQR("hello", "bogus", now); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
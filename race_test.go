@@ -0,0 +1,127 @@
+package fernet
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Hammers the stateful types from many goroutines at once. Run with
+// -race to detect unsynchronized access to shared state.
+func TestConcurrentUse(t *testing.T) {
+	const (
+		secret1 = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		secret2 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		secret3 = "2RrwbX4DMzW67gFZuvAlEnP6UIWq31YnlQbr_FBIc7E="
+	)
+	goroutines, iterations := 16, 200
+	if testing.Short() {
+		iterations = 20
+	}
+	now := time.Now()
+
+	key, err := NewKey(secret1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr := NewKeyRing()
+	if err := kr.Add("k1", secret1); err != nil {
+		t.Fatal(err)
+	}
+	p := &swappableProvider{secrets: []string{secret1}}
+	f := NewFernetWithProvider(p)
+	nf := NewFernet(secret1, p)
+	mf := NewMultiFernet(secret1, secret2)
+
+	// Each of these must round-trip a message. Secret rotations below
+	// always retain secret1, so every call should succeed.
+	roundTrips := map[string]func(msg string) (string, error){
+		"Encrypt": func(msg string) (string, error) {
+			tok, err := Encrypt(msg, secret1, now)
+			if err != nil {
+				return "", err
+			}
+			return Decrypt(tok, secret1, now, time.Minute)
+		},
+		"Key": func(msg string) (string, error) {
+			tok, err := key.Encrypt(msg, now)
+			if err != nil {
+				return "", err
+			}
+			return key.Decrypt(tok, now, time.Minute)
+		},
+		"KeyRing": func(msg string) (string, error) {
+			tok, err := kr.EncryptWithID(msg, now)
+			if err != nil {
+				return "", err
+			}
+			return kr.Decrypt(tok, now, time.Minute)
+		},
+		"Fernet": func(msg string) (string, error) {
+			tok, err := f.Encrypt(msg, now)
+			if err != nil {
+				return "", err
+			}
+			return f.Decrypt(tok, now, time.Minute)
+		},
+		"NewFernet": func(msg string) (string, error) {
+			tok, err := nf.Encrypt(msg, now)
+			if err != nil {
+				return "", err
+			}
+			return nf.Decrypt(tok, now, time.Minute)
+		},
+		"MultiFernet": func(msg string) (string, error) {
+			tok, err := mf.Encrypt(msg, now)
+			if err != nil {
+				return "", err
+			}
+			return mf.WithPrimary(secret3).Decrypt(tok, now, time.Minute)
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*len(roundTrips))
+	for name, roundTrip := range roundTrips {
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(name string, roundTrip func(string) (string, error), g int) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					msg := name + strconv.Itoa(g*iterations+i)
+					got, err := roundTrip(msg)
+					if err == nil && got != msg {
+						err = fmt.Errorf("%s: got %q, want %q", name, got, msg)
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(name, roundTrip, g)
+		}
+	}
+	// Mutate shared state while the round trips run.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			if err := kr.Add("r"+strconv.Itoa(i), secret2); err != nil {
+				errs <- err
+				return
+			}
+			if i%2 == 0 {
+				p.set(secret1, secret2)
+			} else {
+				p.set(secret1)
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package fernet
+
+// Whether the race detector is enabled. It allocates, so tests that
+// count allocations are skipped when it is.
+const raceEnabled = true
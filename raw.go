@@ -0,0 +1,44 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"time"
+)
+
+// EncryptRawSecret is like Encrypt but takes the 32 raw bytes of the
+// secret instead of their base64 encoding, which suits keys held in
+// memory or fetched from a key management service. The first sixteen
+// bytes sign the token and the second sixteen encrypt the message.
+func EncryptRawSecret(msg string, secret []byte, now time.Time) (string, error) {
+	if len(secret) != 2*keyLen {
+		return "", ErrSecretWrongLength
+	}
+	block, _ := aes.NewCipher(secret[keyLen:])
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return "", err
+	}
+	tok := make([]byte, paddedLen(len(msg))+fixedLen)
+	padString(tok[msgOffset:], msg)
+	return encryptPadded(tok, hmac.New(sha256.New, secret[:keyLen]), block, &EncryptOptions{Now: now}, randomIV)
+}
+
+// DecryptRawSecret is like Decrypt but takes the 32 raw bytes of the
+// secret instead of their base64 encoding. See EncryptRawSecret.
+func DecryptRawSecret(token string, secret []byte, now time.Time, ttl time.Duration) (string, error) {
+	if len(secret) != 2*keyLen {
+		return "", ErrSecretWrongLength
+	}
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	tok, err := opts.decodeToken(token)
+	if err != nil {
+		return "", err
+	}
+	block, _ := aes.NewCipher(secret[keyLen:])
+	msg, _, err := decryptToken(tok, hmac.New(sha256.New, secret[:keyLen]), block, &opts)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
@@ -0,0 +1,47 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRawSecret(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	raw, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A token from the string-secret functions decrypts with the raw
+	// secret, and vice versa.
+	msg, err := DecryptRawSecret(token, raw, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+	}
+	tok, err := EncryptRawSecret("hello", raw, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := Decrypt(tok, secret, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello")
+	}
+	if _, err := DecryptRawSecret(token, raw, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	for _, bad := range [][]byte{nil, raw[:31], append(raw[:32:32], 0)} {
+		if _, err := EncryptRawSecret("hello", bad, now); err != ErrSecretWrongLength {
+			t.Fatalf("len %d: got error %v, want %v", len(bad), err, ErrSecretWrongLength)
+		}
+		if _, err := DecryptRawSecret(token, bad, now, time.Minute); err != ErrSecretWrongLength {
+			t.Fatalf("len %d: got error %v, want %v", len(bad), err, ErrSecretWrongLength)
+		}
+	}
+}
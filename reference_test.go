@@ -0,0 +1,180 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests of compatibility with the cryptography.fernet package for
+// Python, whose tokens use the same layout but are not covered by the
+// spec's vectors in fernet_test.go.
+
+// Tokens in the layout of Fernet._encrypt_from_parts, with keys other
+// than the spec's, messages of various lengths, and timestamps beyond
+// 32 bits. To regenerate one:
+//
+//	Fernet(key)._encrypt_from_parts(msg, current_time, bytes.fromhex(iv))
+var pythonVectors = []struct {
+	key, msg    string
+	currentTime uint64
+	iv, token   string
+}{
+	{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "", 0, "000102030405060708090a0b0c0d0e0f", "gAAAAAAAAAAAAAECAwQFBgcICQoLDA0OD7eteCFsVWnW2hqrh_bbxWHLKJrzxA20ewEBMzw7nDvsEBq9eFlezg07tiHgpCCA2w=="},
+	{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "x", 1, "1112131415161718191a1b1c1d1e1f20", "gAAAAAAAAAABERITFBUWFxgZGhscHR4fIKtl7dFYwtzRa2hUKFxNtLTL4LDY_0PwVtHZobZdxQ_SQmj4-suWDUVBewTgYUP2tw=="},
+	{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "fifteen bytes!!", 1505321118, "22232425262728292a2b2c2d2e2f3031", "gAAAAABZuWCeIiMkJSYnKCkqKywtLi8wMfX7znnqniX1DRQ6Ewft0X8uagaRPpN6wD7HVux76aY1ZeS4vh3BGncRtmbZOOnuUQ=="},
+	{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "exactly16bytes!!", 4294967297, "333435363738393a3b3c3d3e3f404142", "gAAAAAEAAAABMzQ1Njc4OTo7PD0-P0BBQg1iSA_y67cbnv4kh9IRZC8lUWP10GZ5f8ltyxukWDPwlvpRgv_eyM6mpuGqEddPsQNAoJTwAs0Y1n6ga9R2W68="},
+	{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", "seventeen bytes!!", 253402300799, "4445464748494a4b4c4d4e4f50515253", "gAAAADr_9EF_REVGR0hJSktMTU5PUFFSU5xphxrcil1wILABkFoWhNx49Jc5BHKgx-37FqWi0-PKL_1F7BqNbA9V2z_cLe2dZ9XGe-8NaWPeS6fjZDZJzrU="},
+	{"_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-8=", "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f", 0, "55565758595a5b5c5d5e5f6061626364", "gAAAAAAAAAAAVVZXWFlaW1xdXl9gYWJjZC9qV-hHB0TzkdTLheCGzGSVk6o2L6Rm8x5NTzZZUaKxktK9ADG1sT249jYyGyduoMtZHWUTkDyKx8_MYn0LAQxoG0T22MBUIqiLB8md5Zw9"},
+	{"_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-8=", "unicode: é世", 1, "666768696a6b6c6d6e6f707172737475", "gAAAAAAAAAABZmdoaWprbG1ub3BxcnN0dfsdDm74ox25wEwMf0b2yQioh95AGUvdyXUHH6CF-hFzqVtZZ8EIZ9oDRkMByBeh8w=="},
+	{"_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-8=", strings.Repeat("\xff", 100), 1505321118, "7778797a7b7c7d7e7f80818283848586", "gAAAAABZuWCed3h5ent8fX5_gIGCg4SFhkr0w_KukwxX8iiaZ2FViQXzpORddURKIv1R1p1WiMsu7Nl9hLwOX2Q2dOvnDhkpMY8tOMaaG-OocJ0w9i9J5JDpGIwe2hh2qFPBxodNKILdIdn_gmuE_pHU2HQLHNEBRvFVIe2bAscRZ6LAF1TZFIyB_M8h9yAjwseOE2mYKMDeeUSAYn8FRbwBMM1RRieJFg=="},
+	{"_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-8=", "", 4294967297, "88898a8b8c8d8e8f9091929394959697", "gAAAAAEAAAABiImKi4yNjo-QkZKTlJWWl0A1412UImdTQLXpbg8AaMCmWzhHB7qPqVMshEqmhD69en_Y5lXWdGZl2pjIs6tZCw=="},
+	{"_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-_-8=", "x", 253402300799, "999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8", "gAAAADr_9EF_mZqbnJ2en6ChoqOkpaanqGGF4UnpCAVHZF4nwncsk_hYRtMK6HCD0jItcf8s9NvWNmkTIOfI3D8K9dBmhzkbfQ=="},
+	{"Zm9vYmFyYmF6cXV4Zm9vYmFyYmF6cXV4Zm9vYmFyYmE=", "fifteen bytes!!", 0, "aaabacadaeafb0b1b2b3b4b5b6b7b8b9", "gAAAAAAAAAAAqqusra6vsLGys7S1tre4uep8dGn7m1k9m8w-QztDQV-ZMbdSBAjhMbwb7bDG0oFe4XH20cymFMsWN1Shbzhp-w=="},
+	{"Zm9vYmFyYmF6cXV4Zm9vYmFyYmF6cXV4Zm9vYmFyYmE=", "exactly16bytes!!", 1, "bbbcbdbebfc0c1c2c3c4c5c6c7c8c9ca", "gAAAAAAAAAABu7y9vr_AwcLDxMXGx8jJyoVf3vWG5K40S5aLWLquwmnjtkfQw9uiuy7Cn7-5e05nKwiX0X75b7AzKDkMayGik1vgitwcAskiu5YvLhYBx4g="},
+	{"Zm9vYmFyYmF6cXV4Zm9vYmFyYmF6cXV4Zm9vYmFyYmE=", "seventeen bytes!!", 1505321118, "cccdcecfd0d1d2d3d4d5d6d7d8d9dadb", "gAAAAABZuWCezM3Oz9DR0tPU1dbX2Nna26hg3cD2t5BPVL1iLRIv0uru6TKlvTkXkdXVjcGvLpo0x-DifAgFvomXF3FfTbCOCJAC2krJ2AUldUqVUM4IRac="},
+	{"Zm9vYmFyYmF6cXV4Zm9vYmFyYmF6cXV4Zm9vYmFyYmE=", "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f", 4294967297, "dddedfe0e1e2e3e4e5e6e7e8e9eaebec", "gAAAAAEAAAAB3d7f4OHi4-Tl5ufo6err7JjqKCO8p7Ed-YuL7_cdtpI0NcW1EPx6JB3TaSgNDDj3p_VQtgeqp68m4Rwm1XDV2uTi-7c7OIx3GRk2qZ4ybLrLGNliVxnqO6nLXNBMaslg"},
+	{"Zm9vYmFyYmF6cXV4Zm9vYmFyYmF6cXV4Zm9vYmFyYmE=", "unicode: é世", 253402300799, "eeeff0f1f2f3f4f5f6f7f8f9fafbfcfd", "gAAAADr_9EF_7u_w8fLz9PX29_j5-vv8_Vp8JulTyy0IbeNkyl6y7ECePEWetKhjst2SkOsOq6uJIZKfmLKisdu5ELfLsQRolQ=="},
+}
+
+// Encrypt must reproduce each token byte for byte, given the same IV and
+// timestamp, and Decrypt must recover its message.
+func TestPythonVectors(t *testing.T) {
+	for i, v := range pythonVectors {
+		iv, err := hex.DecodeString(v.iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Unix(int64(v.currentTime), 0)
+		got, err := encrypt(v.msg, v.key, &EncryptOptions{Now: now}, func(p []byte) error {
+			copy(p, iv)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("vector %d: encrypt error: %s", i, err)
+		}
+		if got != v.token {
+			t.Errorf("vector %d: got %q, want %q", i, got, v.token)
+		}
+		msg, err := Decrypt(v.token, v.key, now, time.Minute)
+		if err != nil {
+			t.Fatalf("vector %d: decrypt error: %s", i, err)
+		}
+		if msg != v.msg {
+			t.Errorf("vector %d: wrong message: got %q, want %q", i, msg, v.msg)
+		}
+	}
+}
+
+// The tests below check the layout against a reference implementation
+// written independently of the package's code.
+
+// Builds a token following Fernet._encrypt_from_parts in the Python
+// package:
+//
+//	padder = padding.PKCS7(algorithms.AES.block_size).padder()
+//	padded_data = padder.update(data) + padder.finalize()
+//	encryptor = Cipher(algorithms.AES(self._encryption_key), modes.CBC(iv)).encryptor()
+//	ciphertext = encryptor.update(padded_data) + encryptor.finalize()
+//	basic_parts = b"\x80" + current_time.to_bytes(length=8, byteorder="big") + iv + ciphertext
+//	h = HMAC(self._signing_key, hashes.SHA256())
+//	h.update(basic_parts)
+//	hmac = h.finalize()
+//	return base64.urlsafe_b64encode(basic_parts + hmac)
+//
+// where the key is split by
+//
+//	self._signing_key = key[:16]
+//	self._encryption_key = key[16:]
+//
+// It is written independently of the package's code, so that a change
+// that breaks compatibility, e.g. in byte order or encoding, shows up as
+// a mismatch.
+func referenceEncrypt(t *testing.T, data []byte, secret string, currentTime uint64, iv []byte) string {
+	t.Helper()
+	key, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte(nil), data...), strings.Repeat(string(rune(n)), n)...)
+	block, err := aes.NewCipher(key[16:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], currentTime)
+	basicParts := append(append(append([]byte{0x80}, ts[:]...), iv...), ciphertext...)
+	h := hmac.New(sha256.New, key[:16])
+	h.Write(basicParts)
+	return base64.URLEncoding.EncodeToString(h.Sum(basicParts))
+}
+
+func TestReferenceTokenLayout(t *testing.T) {
+	secrets := []string{
+		"cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=",
+		"wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=",
+	}
+	times := []uint64{
+		0,
+		499162800,        // the spec's vectors
+		1505321118,       // 2017
+		1<<32 + 1,        // past 2106, beyond 32 bits
+		1<<40 | 0x010203, // distinct bytes, to catch byte-order mistakes
+	}
+	msgs := []string{"", "hello", "exactly16bytes!!", strings.Repeat("\xff", 33), "unicode: é世"}
+	for i, secret := range secrets {
+		for j, ts := range times {
+			for k, msg := range msgs {
+				iv := make([]byte, aes.BlockSize)
+				for b := range iv {
+					iv[b] = byte(i*31 + j*7 + k*3 + b)
+				}
+				want := referenceEncrypt(t, []byte(msg), secret, ts, iv)
+				now := time.Unix(int64(ts), 0)
+				got, err := encrypt(msg, secret, &EncryptOptions{Now: now}, func(p []byte) error {
+					copy(p, iv)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("encrypt error: %s", err)
+				}
+				if got != want {
+					t.Fatalf("secret %d, time %d, message %q: got %q, want %q", i, ts, msg, got, want)
+				}
+				dec, err := Decrypt(want, secret, now, time.Minute)
+				if err != nil {
+					t.Fatalf("secret %d, time %d, message %q: decrypt error: %s", i, ts, msg, err)
+				}
+				if dec != msg {
+					t.Fatalf("wrong message: got %q, want %q", dec, msg)
+				}
+			}
+		}
+	}
+}
+
+// Like Fernet.decrypt_at_time in the Python package, which rejects a
+// token only if timestamp + ttl < current_time, Decrypt accepts a token
+// whose age equals the TTL exactly.
+func TestTTLBoundary(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Unix(1505321118, 0)
+	tok := referenceEncrypt(t, []byte("hello"), secret, uint64(issued.Unix()), make([]byte, aes.BlockSize))
+	if _, err := Decrypt(tok, secret, issued.Add(time.Minute), time.Minute); err != nil {
+		t.Fatalf("decrypt error at the TTL: %s", err)
+	}
+	if _, err := Decrypt(tok, secret, issued.Add(time.Minute+time.Second), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+}
@@ -0,0 +1,26 @@
+package fernet
+
+import (
+	"errors"
+	"time"
+)
+
+// AdjustTimestamp decrypts token and re-encrypts its message with the
+// token's timestamp shifted by delta, which lets an operator extend
+// (positive delta) or shorten (negative delta) the remaining lifetime of
+// existing tokens, since TTLs are measured from the timestamp. The token
+// must be authentic but may have expired. The new token has a fresh IV.
+// Adjustments that would place the timestamp more than the maximum
+// clock skew (one hour) after now are rejected, since Decrypt would
+// reject the result.
+func AdjustTimestamp(token, secret string, delta time.Duration, now time.Time) (string, error) {
+	msg, md, err := decrypt(token, secret, &DecryptOptions{Now: now, TTL: NoTTL})
+	if err != nil {
+		return "", err
+	}
+	ts := md.Timestamp.Add(delta)
+	if ts.Sub(now) > maxClockSkew {
+		return "", errors.New("fernet: adjusted timestamp is too far in the future")
+	}
+	return encrypt(string(msg), secret, &EncryptOptions{Now: ts}, randomIV)
+}
@@ -0,0 +1,56 @@
+package fernet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdjustTimestamp(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	now := issued.Add(50 * time.Minute)
+	tok, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+
+	// Extending by 30 minutes keeps a 1-hour token alive past its
+	// original expiry.
+	longer, err := AdjustTimestamp(tok, secret, 30*time.Minute, now)
+	if err != nil {
+		t.Fatalf("adjust error: %s", err)
+	}
+	later := issued.Add(80 * time.Minute)
+	if _, err := Decrypt(tok, secret, later, time.Hour); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	msg, md, err := DecryptMetadata(longer, secret, later, time.Hour)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" || !md.Timestamp.Equal(issued.Add(30*time.Minute)) {
+		t.Fatalf("got (%q, %v), want (%q, %v)", msg, md.Timestamp, "hello", issued.Add(30*time.Minute))
+	}
+
+	// Shortening by 30 minutes expires it early.
+	shorter, err := AdjustTimestamp(tok, secret, -30*time.Minute, now)
+	if err != nil {
+		t.Fatalf("adjust error: %s", err)
+	}
+	if _, err := Decrypt(shorter, secret, now, time.Hour); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+
+	// Expired tokens can be adjusted, but forged ones cannot.
+	if _, err := AdjustTimestamp(tok, secret, time.Hour, issued.Add(2*time.Hour)); err != nil {
+		t.Fatalf("adjust error: %s", err)
+	}
+	if _, err := AdjustTimestamp(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", time.Hour, now); err == nil {
+		t.Fatal("adjusted a token from another secret")
+	}
+	// Too far into the future.
+	if _, err := AdjustTimestamp(tok, secret, 2*time.Hour, now); err == nil {
+		t.Fatal("expected an error for a timestamp beyond the clock skew")
+	}
+}
@@ -0,0 +1,60 @@
+package fernet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// TokenID returns an identifier for token that is safe to store and
+// log: the hex-encoded first 16 bytes of the SHA-256 hash of the
+// token's HMAC. Since it depends only on the HMAC, tokens that decode
+// to the same bytes have the same ID even if their encodings differ.
+// TokenID does not verify the token.
+func TokenID(token string) (string, error) {
+	tok, err := decodeToken(token)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(tok[len(tok)-sha256.Size:])
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// Leading characters shared by standard tokens: they encode the
+// version and the high-order bits of the timestamp, which are zero for
+// the next hundred thousand years. Redact keeps them since they reveal
+// nothing.
+const redactPrefix = "gAAAA"
+
+// Redact returns a form of token that is safe to log, such as
+// "gAAAA...[len=100]": the first few characters, which identify the
+// version, and the length, with everything else masked. To correlate
+// log entries for the same token, use TokenID. Redact never fails; a
+// string that is not long enough to be a token, or does not begin like
+// a standard one, is masked entirely, since it might be something other
+// than a token, such as a secret logged by mistake.
+func Redact(token string) string {
+	prefix := ""
+	if len(token) >= minEncodedLen && strings.HasPrefix(token, redactPrefix) {
+		prefix = redactPrefix
+	}
+	return prefix + "...[len=" + strconv.Itoa(len(token)) + "]"
+}
+
+// IsRevoked reports whether token's ID (see TokenID) is among revoked.
+// Revoking tokens by ID requires recording each token's ID when it is
+// issued. A malformed token is never reported as revoked, since Decrypt
+// will reject it anyway.
+func IsRevoked(token string, revoked []string) bool {
+	id, err := TokenID(token)
+	if err != nil {
+		return false
+	}
+	for _, r := range revoked {
+		if r == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package fernet
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenID(t *testing.T) {
+	const (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	id, err := TokenID(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 32 {
+		t.Fatalf("got ID %q, want 32 hex digits", id)
+	}
+	// A non-canonical encoding of the same token has the same ID.
+	if id2, err := TokenID(token[:len(token)-3] + "B=="); err != nil || id2 != id {
+		t.Fatalf("got (%q, %v), want (%q, nil)", id2, err, id)
+	}
+	other, err := Encrypt("hello", secret, time.Now())
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if id2, err := TokenID(other); err != nil || id2 == id {
+		t.Fatalf("got (%q, %v), want a different ID", id2, err)
+	}
+	if _, err := TokenID("garbage"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestIsRevoked(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	var (
+		tokens  []string
+		revoked []string
+	)
+	for i := 0; i < 4; i++ {
+		tok, err := Encrypt("hello", secret, time.Now())
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		tokens = append(tokens, tok)
+		if i%2 == 0 {
+			id, err := TokenID(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			revoked = append(revoked, id)
+		}
+	}
+	for i, tok := range tokens {
+		if got, want := IsRevoked(tok, revoked), i%2 == 0; got != want {
+			t.Errorf("IsRevoked(token %d) = %t, want %t", i, got, want)
+		}
+	}
+	if IsRevoked("garbage", revoked) {
+		t.Error("malformed token reported as revoked")
+	}
+	if IsRevoked(tokens[0], nil) {
+		t.Error("token revoked by an empty list")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	const token = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	if got, want := Redact(token), "gAAAA...[len=100]"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	tests := []struct{ in, want string }{
+		{"", "...[len=0]"},
+		{"hunter2", "...[len=7]"},
+		{token[:50], "...[len=50]"},
+		{"not a token at all, but long enough to look like one: " + token[:50], "...[len=104]"},
+		{"gQAAAAAdwJ6wQ8GRfv-iibwY6qBXVaO8ZU9TcMHFA_XDv5UI3hEfpPJBMfoKof-xgauZjeaed2JbQNyzuGZdkduHXGsQX0NS7Q==", "...[len=100]"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	// No run of the token's body survives.
+	got := Redact(token)
+	for i := len(redactPrefix); i+4 <= len(token); i++ {
+		if strings.Contains(got, token[i:i+4]) {
+			t.Fatalf("redacted token %q contains %q from the body", got, token[i:i+4])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package fernet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ValidateSecret reports whether secret is suitable for Encrypt. The
+// error, if any, matches ErrSecretNotBase64 or ErrSecretWrongLength
+// according to errors.Is.
+func ValidateSecret(secret string) error {
+	_, err := decodeSecret(base64.URLEncoding, secret)
+	return err
+}
+
+// ErrZeroSecret is returned by ValidateSecretStrict and NewKeyStrict for
+// a secret whose bytes are all zero, which usually means a placeholder
+// was never replaced.
+var ErrZeroSecret = errors.New("fernet: secret is all zeros")
+
+// ValidateSecretStrict is like ValidateSecret but also rejects a secret
+// whose bytes are all zero with ErrZeroSecret. Such a secret works, so
+// the rest of the package accepts it, but it offers no protection; use
+// this to check configuration at startup.
+func ValidateSecretStrict(secret string) error {
+	keys, err := decodeSecret(base64.URLEncoding, secret)
+	if err != nil {
+		return err
+	}
+	var zero [2 * keyLen]byte
+	if subtle.ConstantTimeCompare(keys, zero[:]) == 1 {
+		return ErrZeroSecret
+	}
+	return nil
+}
+
+// ErrKnownWeakSecret is returned when EncryptOptions or DecryptOptions
+// has RejectKnownWeakSecrets set and the secret is one published in
+// examples, such as the one in the Fernet spec's test vectors.
+var ErrKnownWeakSecret = errors.New("fernet: secret is a published example")
+
+// Secrets that appear in published examples and so are known to
+// attackers. They are compared by their decoded bytes, so that other
+// encodings of them are caught too.
+var knownWeakSecrets = []string{
+	"cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=", // the Fernet spec's test vectors
+	"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", // all zeros
+}
+
+// Reports whether the decoded keys are those of a known weak secret.
+func isKnownWeakSecret(signing, encryption []byte) bool {
+	for _, weak := range knownWeakSecrets {
+		keys, _ := base64.URLEncoding.DecodeString(weak)
+		if bytes.Equal(signing, keys[:keyLen]) && bytes.Equal(encryption, keys[keyLen:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRotation checks a list of secrets meant for a KeyRing or
+// MultiFernet at startup: there must be at least one, each must be
+// valid for Encrypt, and no two may be the same key, which is almost
+// always a configuration mistake. Rather than stop at the first
+// problem, it reports them all, joined with errors.Join; errors.Is
+// matches the underlying ErrSecretNotBase64 and ErrSecretWrongLength.
+func ValidateRotation(secrets []string) error {
+	if len(secrets) == 0 {
+		return errors.New("fernet: no secrets")
+	}
+	var (
+		errs []error
+		seen = make(map[string]int) // index by decoded key
+	)
+	for i, secret := range secrets {
+		keys, err := decodeSecret(base64.URLEncoding, secret)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fernet: secret %d: %w", i, err))
+			continue
+		}
+		if j, ok := seen[string(keys)]; ok {
+			errs = append(errs, fmt.Errorf("fernet: secret %d is the same as secret %d", i, j))
+			continue
+		}
+		seen[string(keys)] = i
+	}
+	return errors.Join(errs...)
+}
+
+// SecretFromHex converts a hex-encoded secret into the base64-encoded
+// form expected by Encrypt and Decrypt. hexKey must consist of exactly
+// 64 hex digits, in either case. To accept the output of various key
+// tools, an optional "0x" prefix is removed, as are any colons and
+// white space, as in "73:0f:f4:..." or "730ff4c7 af3d4692 ...".
+func SecretFromHex(hexKey string) (string, error) {
+	hexKey = strings.TrimSpace(hexKey)
+	if strings.HasPrefix(hexKey, "0x") || strings.HasPrefix(hexKey, "0X") {
+		hexKey = hexKey[2:]
+	}
+	hexKey = strings.Map(func(r rune) rune {
+		if r == ':' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, hexKey)
+	if len(hexKey) != hex.EncodedLen(2*keyLen) {
+		return "", errors.New("fernet: hex secret must be 64 characters")
+	}
+	keys, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return "", fmt.Errorf("fernet: failed to decode hex secret: %v", err)
+	}
+	return base64.URLEncoding.EncodeToString(keys), nil
+}
+
+// SecretFromHalves combines the signing and encryption keys of a secret,
+// stored separately as base64-encoded 16-byte halves by some tools, into
+// the form expected by Encrypt and Decrypt. Both halves use the same
+// URL-safe base64 encoding as secrets.
+func SecretFromHalves(signingB64, encryptionB64 string) (string, error) {
+	var keys [2 * keyLen]byte
+	halves := []struct {
+		name, b64 string
+		dst       []byte
+	}{
+		{"signing", signingB64, keys[:keyLen]},
+		{"encryption", encryptionB64, keys[keyLen:]},
+	}
+	for _, h := range halves {
+		b, err := base64.URLEncoding.DecodeString(h.b64)
+		if err != nil {
+			return "", fmt.Errorf("fernet: failed to decode %s key: %v", h.name, err)
+		}
+		if len(b) != keyLen {
+			return "", fmt.Errorf("fernet: %s key must be %d bytes", h.name, keyLen)
+		}
+		copy(h.dst, b)
+	}
+	return base64.URLEncoding.EncodeToString(keys[:]), nil
+}
+
+// SecretFromConfig cleans up a secret read from a configuration file,
+// where it may have been surrounded by white space or quotes, and checks
+// that the result is a valid secret. Only a single matching pair of
+// single or double quotes is removed.
+func SecretFromConfig(raw string) (string, error) {
+	secret := strings.TrimSpace(raw)
+	if n := len(secret); n >= 2 && (secret[0] == '"' || secret[0] == '\'') && secret[n-1] == secret[0] {
+		secret = strings.TrimSpace(secret[1 : n-1])
+	}
+	if _, _, err := extractKeys(secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ParseNamedSecret splits s, a secret labeled with a key ID in the form
+// "keyID:secret", into its parts, e.g. for use with KeyRing.Add. Since
+// secrets never contain a colon, the key ID may. Both parts are
+// validated: the key ID must be between 1 and 255 bytes long, and the
+// secret must be valid for Encrypt.
+func ParseNamedSecret(s string) (id, secret string, err error) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return "", "", errors.New("fernet: named secret must have the form keyID:secret")
+	}
+	id, secret = s[:i], s[i+1:]
+	if len(id) == 0 || len(id) > 255 {
+		return "", "", errors.New("fernet: key ID must be between 1 and 255 bytes")
+	}
+	if err := ValidateSecret(secret); err != nil {
+		return "", "", err
+	}
+	return id, secret, nil
+}
+
+// KeyFingerprint returns a short identifier for secret that is safe to
+// log: the first eight bytes of the SHA-256 hash of the decoded secret,
+// in hex. Fingerprints of different secrets almost never collide.
+func KeyFingerprint(secret string) (string, error) {
+	keys, err := decodeSecret(base64.URLEncoding, secret)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(keys)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// WriteSecretFile generates a new secret with RandomSecret and writes it
+// to a new file at path that only its owner can read or write, e.g. on
+// an application's first run. It fails rather than overwrite an existing
+// file. The secret is also returned, so that it can be used right away.
+func WriteSecretFile(path string) (secret string, err error) {
+	secret, err = RandomSecret()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(secret)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return secret, nil
+}
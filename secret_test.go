@@ -0,0 +1,398 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretFromHex(t *testing.T) {
+	const (
+		hexKey = "730ff4c7af3d46923e8ed451ee813c87f790b0a226bc96a92de49b5e9c05e1ee"
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	)
+	var tests = []struct {
+		desc, hexKey, want string
+		ok                 bool
+	}{
+		{"valid", hexKey, secret, true},
+		{"too short", hexKey[:62], "", false},
+		{"too long", hexKey + "00", "", false},
+		{"not hex", "zz" + hexKey[2:], "", false},
+		{"empty", "", "", false},
+		{"upper case", strings.ToUpper(hexKey), secret, true},
+		{"0x prefix", "0x" + hexKey, secret, true},
+		{"0X prefix", "0X" + strings.ToUpper(hexKey), secret, true},
+		{"colons", colonHex(hexKey), secret, true},
+		{"colons, 0x prefix", "0x" + colonHex(hexKey), secret, true},
+		{"white space", " " + hexKey[:32] + " \t" + hexKey[32:] + "\n", secret, true},
+		{"colons, too short", colonHex(hexKey[:62]), "", false},
+		{"0x only", "0x", "", false},
+		{"two prefixes", "0x0x" + hexKey, "", false},
+		{"dashes", hexKey[:32] + "-" + hexKey[32:], "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := SecretFromHex(tt.hexKey)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Formats s in pairs of hex digits separated by colons.
+func colonHex(s string) string {
+	var pairs []string
+	for i := 0; i < len(s); i += 2 {
+		pairs = append(pairs, s[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
+
+func TestDecryptHexSecret(t *testing.T) {
+	var (
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now    = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		hexKey = "730ff4c7af3d46923e8ed451ee813c87f790b0a226bc96a92de49b5e9c05e1ee"
+	)
+	opts := DecryptOptions{Now: now, TTL: time.Minute, HexSecret: true}
+	msg, err := DecryptWithOptions(token, hexKey, opts)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+	}
+	if _, err := DecryptWithOptions(token, strings.ToUpper(hexKey[:63]), opts); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestSecretFromHalves(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	keys, _ := base64.URLEncoding.DecodeString(secret)
+	signing := base64.URLEncoding.EncodeToString(keys[:16])
+	encryption := base64.URLEncoding.EncodeToString(keys[16:])
+	got, err := SecretFromHalves(signing, encryption)
+	if err != nil {
+		t.Fatalf("SecretFromHalves error: %s", err)
+	}
+	if got != secret {
+		t.Fatalf("got %q, want %q", got, secret)
+	}
+	now := time.Now()
+	token, err := Encrypt("hello", got, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := Decrypt(token, secret, now, time.Minute); err != nil || msg != "hello" {
+		t.Fatalf("got %q, %v; want %q, nil", msg, err, "hello")
+	}
+	short := base64.URLEncoding.EncodeToString(keys[:15])
+	for _, tt := range []struct{ desc, signing, encryption string }{
+		{"signing key too short", short, encryption},
+		{"encryption key too short", signing, short},
+		{"whole secret", secret, encryption},
+		{"not base64", "%" + signing[1:], encryption},
+		{"empty", "", ""},
+	} {
+		if _, err := SecretFromHalves(tt.signing, tt.encryption); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+}
+
+func TestSecretFromConfig(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	var tests = []struct {
+		raw string
+		ok  bool
+	}{
+		{secret, true},
+		{`"` + secret + `"`, true},
+		{`'` + secret + `'`, true},
+		{"  " + secret + "\n", true},
+		{` "` + secret + `" `, true},
+		{`" ` + secret + ` "`, true},
+		{`"` + secret + `'`, false},
+		{`"` + secret, false},
+		{`""` + secret + `""`, false},
+		{"", false},
+		{`""`, false},
+		{"not a secret", false},
+	}
+	for _, tt := range tests {
+		got, err := SecretFromConfig(tt.raw)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("SecretFromConfig(%q): expected an error", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SecretFromConfig(%q): %s", tt.raw, err)
+		} else if got != secret {
+			t.Errorf("SecretFromConfig(%q) = %q, want %q", tt.raw, got, secret)
+		}
+	}
+}
+
+func TestParseNamedSecret(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	var tests = []struct {
+		s  string
+		id string
+		ok bool
+	}{
+		{"2017:" + secret, "2017", true},
+		{"prod:v2:" + secret, "prod:v2", true},
+		{secret, "", false},
+		{":" + secret, "", false},
+		{strings.Repeat("x", 256) + ":" + secret, "", false},
+		{"2017:", "", false},
+		{"2017:not a secret", "", false},
+		{"2017:" + secret[:40], "", false},
+	}
+	for _, tt := range tests {
+		id, got, err := ParseNamedSecret(tt.s)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("ParseNamedSecret(%q): expected an error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNamedSecret(%q): %s", tt.s, err)
+		} else if id != tt.id || got != secret {
+			t.Errorf("ParseNamedSecret(%q) = (%q, %q), want (%q, %q)", tt.s, id, got, tt.id, secret)
+		}
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	fp1, err := KeyFingerprint("cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256 of the decoded secret begins with these eight bytes.
+	if want := "cbeb362f1fa69a66"; fp1 != want {
+		t.Fatalf("got %q, want %q", fp1, want)
+	}
+	fp2, err := KeyFingerprint("wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp1 == fp2 {
+		t.Fatal("different secrets have the same fingerprint")
+	}
+	if _, err := KeyFingerprint("bogus"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDecryptSecretEncoding(t *testing.T) {
+	var (
+		token     = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now       = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+		urlSecret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		stdSecret = "cw/0x689RpI+jtRR7oE8h/eQsKImvJapLeSbXpwF4e4="
+		rawSecret = "cw/0x689RpI+jtRR7oE8h/eQsKImvJapLeSbXpwF4e4"
+	)
+	var tests = []struct {
+		desc   string
+		secret string
+		enc    *base64.Encoding
+		ok     bool
+	}{
+		{"default", urlSecret, nil, true},
+		{"explicit URL encoding", urlSecret, base64.URLEncoding, true},
+		{"standard encoding", stdSecret, base64.StdEncoding, true},
+		{"unpadded standard encoding", rawSecret, base64.RawStdEncoding, true},
+		{"standard secret with default encoding", stdSecret, nil, false},
+		{"URL secret with standard encoding", urlSecret, base64.StdEncoding, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			msg, err := DecryptWithOptions(token, tt.secret, DecryptOptions{
+				Now:            now,
+				TTL:            time.Minute,
+				SecretEncoding: tt.enc,
+			})
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decrypt error: %s", err)
+			}
+			if msg != "hello" {
+				t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+			}
+		})
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	var (
+		token = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+		now   = time.Date(1985, time.October, 26, 8, 20, 01, 0, time.UTC)
+	)
+	var tests = []struct {
+		desc   string
+		secret string
+		want   error
+	}{
+		{"valid", "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4=", nil},
+		{"not base64", "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4!", ErrSecretNotBase64},
+		{"standard alphabet", "cw/0x689RpI+jtRR7oE8h/eQsKImvJapLeSbXpwF4e4=", ErrSecretNotBase64},
+		{"too short", "cw_0x689RpI-jtRR7oE8h_eQsKImvJap", ErrSecretWrongLength},
+		{"too long", "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4A", ErrSecretWrongLength},
+		{"empty", "", ErrSecretWrongLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			check := func(name string, err error) {
+				if tt.want == nil {
+					if err != nil {
+						t.Errorf("%s: unexpected error: %s", name, err)
+					}
+				} else if !errors.Is(err, tt.want) {
+					t.Errorf("%s: got error %v, want %v", name, err, tt.want)
+				}
+			}
+			check("ValidateSecret", ValidateSecret(tt.secret))
+			_, err := Encrypt("hello", tt.secret, now)
+			check("Encrypt", err)
+			_, err = Decrypt(token, tt.secret, now, time.Minute)
+			if tt.want != nil {
+				check("Decrypt", err)
+			}
+		})
+	}
+}
+
+func TestValidateSecretStrict(t *testing.T) {
+	const zero = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	// The lenient default accepts an all-zero secret.
+	if err := ValidateSecret(zero); err != nil {
+		t.Fatalf("ValidateSecret: unexpected error: %s", err)
+	}
+	if err := ValidateSecretStrict(zero); err != ErrZeroSecret {
+		t.Fatalf("ValidateSecretStrict: got error %v, want %v", err, ErrZeroSecret)
+	}
+	if _, err := NewKeyStrict(zero); err != ErrZeroSecret {
+		t.Fatalf("NewKeyStrict: got error %v, want %v", err, ErrZeroSecret)
+	}
+	// A single nonzero bit suffices.
+	const almostZero = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE="
+	if err := ValidateSecretStrict(almostZero); err != nil {
+		t.Fatalf("ValidateSecretStrict: unexpected error: %s", err)
+	}
+	if _, err := NewKeyStrict(almostZero); err != nil {
+		t.Fatalf("NewKeyStrict: unexpected error: %s", err)
+	}
+	if err := ValidateSecretStrict("bogus"); !errors.Is(err, ErrSecretWrongLength) && !errors.Is(err, ErrSecretNotBase64) {
+		t.Fatalf("got error %v, want a secret error", err)
+	}
+}
+
+func TestValidateRotation(t *testing.T) {
+	const (
+		secret1 = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		secret2 = "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="
+		// Decodes to the same key as secret1; the unused bits of the
+		// last character differ.
+		secret1Alt = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e5="
+	)
+	tests := []struct {
+		desc    string
+		secrets []string
+		want    []string // substrings of the error, one per problem
+		is      []error
+	}{
+		{"one", []string{secret1}, nil, nil},
+		{"two", []string{secret1, secret2}, nil, nil},
+		{"nil", nil, []string{"no secrets"}, nil},
+		{"empty", []string{}, []string{"no secrets"}, nil},
+		{"duplicate", []string{secret1, secret2, secret1}, []string{"secret 2 is the same as secret 0"}, nil},
+		{"same key, different encoding", []string{secret1, secret1Alt}, []string{"secret 1 is the same as secret 0"}, nil},
+		{
+			"several problems",
+			[]string{"bogus!", secret2, secret2, secret1[:20]},
+			[]string{"secret 0", "secret 2 is the same as secret 1", "secret 3"},
+			[]error{ErrSecretNotBase64, ErrSecretWrongLength},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := ValidateRotation(tt.secrets)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("error %q does not mention %q", err, s)
+				}
+			}
+			for _, target := range tt.is {
+				if !errors.Is(err, target) {
+					t.Errorf("error %q does not match %v", err, target)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	secret, err := WriteSecretFile(path)
+	if err != nil {
+		t.Fatalf("WriteSecretFile error: %s", err)
+	}
+	if err := ValidateSecret(secret); err != nil {
+		t.Fatalf("invalid secret: %s", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != secret {
+		t.Fatalf("file contains %q, want %q", b, secret)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Fatalf("got mode %v, want %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+	if _, err := WriteSecretFile(path); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got error %v, want %v", err, os.ErrExist)
+	}
+	if b2, _ := os.ReadFile(path); string(b2) != secret {
+		t.Fatal("existing file was overwritten")
+	}
+}
@@ -0,0 +1,40 @@
+package fernet
+
+import "time"
+
+// SecureBytes holds a decrypted message that the caller wants to wipe
+// from memory once it is no longer needed, which a string does not
+// allow.
+//
+// Destroy clears only the buffer SecureBytes holds. Go may have copied
+// the message elsewhere, e.g. if the garbage collector moved it or if
+// the caller converted it to a string, and those copies are not wiped.
+// SecureBytes limits how long the plaintext lingers; it does not
+// guarantee that no trace of it remains.
+type SecureBytes struct {
+	b []byte
+}
+
+// Bytes returns the message. The slice is valid only until Destroy is
+// called, after which it holds zeros.
+func (s *SecureBytes) Bytes() []byte { return s.b }
+
+// Destroy overwrites the message, and the padding stored after it, with
+// zeros. It is safe to call more than once.
+func (s *SecureBytes) Destroy() {
+	b := s.b[:cap(s.b)]
+	for i := range b {
+		b[i] = 0
+	}
+	s.b = s.b[:0]
+}
+
+// DecryptSecure is like Decrypt but returns the message as SecureBytes,
+// so that it can be wiped after use. See Decrypt.
+func DecryptSecure(token, secret string, now time.Time, ttl time.Duration) (*SecureBytes, error) {
+	msg, _, err := decrypt(token, secret, &DecryptOptions{Now: now, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	return &SecureBytes{b: msg}, nil
+}
@@ -0,0 +1,37 @@
+package fernet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecryptSecure(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := Encrypt("correct horse battery staple", secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	s, err := DecryptSecure(tok, secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	b := s.Bytes()
+	if string(b) != "correct horse battery staple" {
+		t.Fatalf("wrong message: got %q", b)
+	}
+	s.Destroy()
+	for i, c := range b[:cap(b)] {
+		if c != 0 {
+			t.Fatalf("byte %d is %#x after Destroy", i, c)
+		}
+	}
+	if len(s.Bytes()) != 0 {
+		t.Fatalf("Bytes returned %d bytes after Destroy", len(s.Bytes()))
+	}
+	s.Destroy() // must not panic
+
+	if _, err := DecryptSecure(tok, secret, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("expected an expiry error")
+	}
+}
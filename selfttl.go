@@ -0,0 +1,35 @@
+package fernet
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// EncryptWithTTL is like Encrypt but embeds ttl in the token, so that
+// every verifier enforces the TTL chosen by the token's creator. Such
+// tokens must be decrypted with DecryptSelfTTL. This is an extension to
+// the Fernet spec: the token has its own version byte and cannot be
+// decrypted by other Fernet implementations.
+func EncryptWithTTL(msg, secret string, now time.Time, ttl time.Duration) (string, error) {
+	if ttl < 0 {
+		return "", errors.New("fernet: negative TTL")
+	}
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(ttl))
+	return seal(&format{version: versionSelfTTL}, header[:], msg, secret, now, randomIV)
+}
+
+// DecryptSelfTTL decrypts a token created by EncryptWithTTL, enforcing
+// the TTL embedded in the token.
+func DecryptSelfTTL(token, secret string, now time.Time) (string, error) {
+	msg, ts, header, err := open(&format{version: versionSelfTTL}, 8, token, secret)
+	if err != nil {
+		return "", err
+	}
+	ttl := time.Duration(binary.BigEndian.Uint64(header))
+	if err := checkAge(ts, now, ttl); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
@@ -0,0 +1,57 @@
+package fernet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSelfTTL(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := EncryptWithTTL("hello", secret, issued, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	msg, err := DecryptSelfTTL(tok, secret, issued.Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("wrong message: got %q, want %q", msg, "hello")
+	}
+	if _, err := DecryptSelfTTL(tok, secret, issued.Add(10*time.Minute+time.Second)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := DecryptSelfTTL(tok, secret, issued.Add(-2*time.Hour)); err == nil {
+		t.Fatal("expected a clock skew error")
+	}
+	if _, err := Decrypt(tok, secret, issued, time.Hour); err == nil {
+		t.Fatal("standard Decrypt accepted a self-TTL token")
+	}
+	std, err := Encrypt("hello", secret, issued)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptSelfTTL(std, secret, issued); err == nil {
+		t.Fatal("DecryptSelfTTL accepted a standard token")
+	}
+	if _, err := EncryptWithTTL("hello", secret, issued, -time.Second); err == nil {
+		t.Fatal("expected an error for a negative TTL")
+	}
+}
+
+func TestSelfTTLTampered(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := EncryptWithTTL("hello", secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	// Flip a bit in the embedded TTL.
+	b := []byte(tok)
+	b[14] ^= 1
+	if _, err := DecryptSelfTTL(string(b), secret, now); err == nil {
+		t.Fatal("expected an error")
+	}
+}
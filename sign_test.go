@@ -0,0 +1,66 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	for _, msg := range []string{"", "hello", strings.Repeat("public payload ", 100)} {
+		tok, err := Sign(msg, secret, now)
+		if err != nil {
+			t.Fatalf("sign error: %s", err)
+		}
+		st, err := Verify(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("verify error: %s", err)
+		}
+		if st.Message != msg || !st.Timestamp.Equal(now) {
+			t.Fatalf("got (%q, %s), want (%q, %s)", st.Message, st.Timestamp, msg, now)
+		}
+		// The message is in the clear.
+		raw, _ := base64.URLEncoding.DecodeString(tok)
+		if !strings.Contains(string(raw), msg) {
+			t.Fatal("message is not stored in the clear")
+		}
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := Sign("amount=100", secret, now)
+	if err != nil {
+		t.Fatalf("sign error: %s", err)
+	}
+	raw, _ := base64.URLEncoding.DecodeString(tok)
+	for i := range raw {
+		tampered := append([]byte(nil), raw...)
+		tampered[i] ^= 1
+		if _, err := Verify(base64.URLEncoding.EncodeToString(tampered), secret, now, time.Minute); err == nil {
+			t.Fatalf("accepted a token with byte %d changed", i)
+		}
+	}
+	if _, err := Verify(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("wrong secret: got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if _, err := Verify(tok, secret, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := Verify(base64.URLEncoding.EncodeToString(raw[:40]), secret, now, time.Minute); err != ErrTokenTooShort {
+		t.Fatalf("got error %v, want %v", err, ErrTokenTooShort)
+	}
+	// Signed and encrypted tokens are not interchangeable.
+	if _, err := Decrypt(tok, secret, now, time.Minute); err == nil {
+		t.Fatal("Decrypt accepted a signed token")
+	}
+	enc, _ := Encrypt("amount=100", secret, now)
+	if _, err := Verify(enc, secret, now, time.Minute); err != ErrWrongVersion {
+		t.Fatalf("got error %v, want %v", err, ErrWrongVersion)
+	}
+}
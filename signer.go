@@ -0,0 +1,99 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+)
+
+// A Signer computes and checks the HMAC-SHA256 signatures of tokens,
+// which lets the signing key be held elsewhere, such as in a hardware
+// security module. See EncryptWithSigner.
+type Signer interface {
+	// Sign returns the 32-byte signature of data.
+	Sign(data []byte) []byte
+
+	// Verify reports whether mac is the signature of data.
+	Verify(data, mac []byte) bool
+}
+
+// NewHMACSigner returns a Signer that computes HMAC-SHA256 with
+// signingKey, which must be 16 bytes long, exactly as Encrypt does with
+// the first half of its secret. It is safe for concurrent use.
+func NewHMACSigner(signingKey []byte) (Signer, error) {
+	if len(signingKey) != keyLen {
+		return nil, ErrSecretWrongLength
+	}
+	return hmacSigner(append([]byte(nil), signingKey...)), nil
+}
+
+type hmacSigner []byte
+
+func (key hmacSigner) Sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (key hmacSigner) Verify(data, mac []byte) bool {
+	return hmac.Equal(key.Sign(data), mac)
+}
+
+// EncryptWithSigner is like EncryptWithBlock but signs the token with s
+// instead of computing the HMAC itself, so that the signing key, the
+// encryption key, or both can be kept out of the process. With the
+// signer returned by NewHMACSigner and an AES-128 block, the result is a
+// standard token.
+func EncryptWithSigner(msg string, s Signer, block cipher.Block, now time.Time) (string, error) {
+	if block.BlockSize() != aes.BlockSize {
+		return "", errBlockSize
+	}
+	if err := checkMessageLen(len(msg), 0); err != nil {
+		return "", err
+	}
+	tok := make([]byte, paddedLen(len(msg))+fixedLen)
+	padString(tok[msgOffset:], msg)
+	if err := encryptUnsigned(tok, block, &EncryptOptions{Now: now}, randomIV); err != nil {
+		return "", err
+	}
+	macOffset := len(tok) - sha256.Size
+	mac := s.Sign(tok[:macOffset])
+	if len(mac) != sha256.Size {
+		return "", errors.New("fernet: signature must be 32 bytes")
+	}
+	copy(tok[macOffset:], mac)
+	return encodeToken(tok), nil
+}
+
+// DecryptWithSigner decrypts a token whose signature is checked by s.
+// block is not used unless s verifies the token. See EncryptWithSigner
+// and Decrypt.
+func DecryptWithSigner(token string, s Signer, block cipher.Block, now time.Time, ttl time.Duration) (string, error) {
+	if block.BlockSize() != aes.BlockSize {
+		return "", errBlockSize
+	}
+	opts := DecryptOptions{Now: now, TTL: ttl}
+	tok, err := opts.decodeToken(token)
+	if err != nil {
+		return "", err
+	}
+	macOffset := len(tok) - sha256.Size
+	if (macOffset-msgOffset)%aes.BlockSize != 0 {
+		return "", ErrCiphertextLength
+	}
+	if !s.Verify(tok[:macOffset], tok[macOffset:]) {
+		return "", ErrWrongHMAC
+	}
+	text, _, err := decryptVerified(tok, block, &opts)
+	if err != nil {
+		return "", err
+	}
+	msg := unpad(text)
+	if msg == nil {
+		return "", ErrInvalidPadding
+	}
+	return string(msg), nil
+}
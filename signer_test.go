@@ -0,0 +1,68 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A Signer whose signatures are the wrong length.
+type shortSigner struct{}
+
+func (shortSigner) Sign(data []byte) []byte      { return make([]byte, 16) }
+func (shortSigner) Verify(data, mac []byte) bool { return true }
+
+func TestSigner(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		token  = "gAAAAAAdwJ6wAAECAwQFBgcICQoLDA0ODy021cpGVWKZ_eEwCGM4BLLF_5CV9dOPmrhuVUPgJobwOz7JcbmrR64jVmpU4IwqDA=="
+	)
+	now := time.Date(1985, time.October, 26, 8, 20, 1, 0, time.UTC)
+	keys, err := base64.URLEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewHMACSigner(keys[:keyLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(keys[keyLen:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The default signer matches the built-in HMAC.
+	msg, err := DecryptWithSigner(token, s, block, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("got %q, want %q", msg, "hello")
+	}
+	tok, err := EncryptWithSigner("hello, world", s, block, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if msg, err := Decrypt(tok, secret, now, time.Minute); err != nil || msg != "hello, world" {
+		t.Fatalf("got (%q, %v), want (%q, nil)", msg, err, "hello, world")
+	}
+
+	other, err := NewHMACSigner(make([]byte, keyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptWithSigner(token, other, block, now, time.Minute); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if _, err := DecryptWithSigner(token, s, block, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := EncryptWithSigner("hello", shortSigner{}, block, now); err == nil {
+		t.Fatal("expected an error for a short signature")
+	}
+	if _, err := NewHMACSigner(keys); err != ErrSecretWrongLength {
+		t.Fatalf("got error %v, want %v", err, ErrSecretWrongLength)
+	}
+}
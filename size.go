@@ -0,0 +1,70 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"encoding/base64"
+	"math"
+)
+
+// EncodedTokenLen returns the length of the token that Encrypt produces
+// for a message of n bytes.
+func EncodedTokenLen(n int) int {
+	return base64.URLEncoding.EncodedLen(paddedLen(n) + fixedLen)
+}
+
+// MaxMessageLen returns the length of the longest message whose token
+// is no longer than maxEncodedLen, which is useful when tokens must fit
+// in a URL or QR code. Returns -1 if no token is that short.
+func MaxMessageLen(maxEncodedLen int) int {
+	if maxEncodedLen < 0 {
+		return -1
+	}
+	// Encoded tokens are padded, so every 4 characters hold 3 bytes.
+	n := base64.URLEncoding.DecodedLen(maxEncodedLen) - fixedLen
+	if n < 0 {
+		return -1
+	}
+	// The ciphertext is a whole number of blocks, and PKCS #7 padding
+	// always adds at least one byte.
+	return aes.BlockSize*(n/aes.BlockSize) - 1
+}
+
+// MinTokenLen returns the length of the shortest valid token, which is
+// the token of an empty message. Anything shorter can be rejected
+// without calling Decrypt.
+func MinTokenLen() int {
+	return minEncodedLen
+}
+
+// MaxTokenLen returns the length of the longest token whose message is
+// no longer than maxMessageLen bytes. Together with MinTokenLen, it lets
+// a gateway reject tokens of implausible length before calling Decrypt.
+func MaxTokenLen(maxMessageLen int) int {
+	if maxMessageLen < 0 {
+		return -1
+	}
+	return EncodedTokenLen(maxMessageLen)
+}
+
+// SafeTokenCount returns how many tokens can be created with one secret
+// before the probability that two of them share an IV exceeds
+// collisionProbability, assuming IVs are uniformly random. An IV
+// collision reveals information about the two messages, so this is a
+// guide to how often secrets should be rotated. It uses the birthday
+// bound for a 128-bit space, n = sqrt(2 * 2^128 * ln(1/(1-p))), and
+// saturates at math.MaxUint64. It returns 0 if collisionProbability is
+// not positive.
+func SafeTokenCount(collisionProbability float64) uint64 {
+	p := collisionProbability
+	switch {
+	case !(p > 0): // also catches NaN
+		return 0
+	case p >= 1:
+		return math.MaxUint64
+	}
+	n := math.Sqrt(2 * math.Ldexp(1, 8*aes.BlockSize) * -math.Log1p(-p))
+	if n >= math.Ldexp(1, 64) {
+		return math.MaxUint64
+	}
+	return uint64(n)
+}
@@ -0,0 +1,101 @@
+package fernet
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodedTokenLen(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	for n := 0; n < 100; n++ {
+		tok, err := Encrypt(strings.Repeat("x", n), secret, time.Now())
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		if got := EncodedTokenLen(n); got != len(tok) {
+			t.Fatalf("EncodedTokenLen(%d) = %d, want %d", n, got, len(tok))
+		}
+	}
+}
+
+func TestMaxMessageLen(t *testing.T) {
+	minLen := EncodedTokenLen(0)
+	for n := 0; n < minLen; n++ {
+		if got := MaxMessageLen(n); got != -1 {
+			t.Fatalf("MaxMessageLen(%d) = %d, want -1", n, got)
+		}
+	}
+	for n := minLen; n < 2000; n++ {
+		m := MaxMessageLen(n)
+		if m < 0 {
+			t.Fatalf("MaxMessageLen(%d) = %d, want a non-negative length", n, m)
+		}
+		if EncodedTokenLen(m) > n {
+			t.Fatalf("EncodedTokenLen(MaxMessageLen(%d)) = %d, want <= %d", n, EncodedTokenLen(m), n)
+		}
+		if EncodedTokenLen(m+1) <= n {
+			t.Fatalf("MaxMessageLen(%d) = %d, but a message of length %d also fits", n, m, m+1)
+		}
+	}
+}
+
+func TestMinTokenLen(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	tok, err := Encrypt("", secret, time.Now())
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if got := MinTokenLen(); got != len(tok) {
+		t.Fatalf("MinTokenLen() = %d, want %d", got, len(tok))
+	}
+}
+
+func TestMaxTokenLen(t *testing.T) {
+	if got := MaxTokenLen(-1); got != -1 {
+		t.Fatalf("MaxTokenLen(-1) = %d, want -1", got)
+	}
+	if got := MaxTokenLen(0); got != MinTokenLen() {
+		t.Fatalf("MaxTokenLen(0) = %d, want %d", got, MinTokenLen())
+	}
+	for n := 0; n < 100; n++ {
+		// Every message up to n bytes yields a token that fits.
+		for m := 0; m <= n; m++ {
+			if EncodedTokenLen(m) > MaxTokenLen(n) {
+				t.Fatalf("MaxTokenLen(%d) = %d, but a %d-byte message needs %d", n, MaxTokenLen(n), m, EncodedTokenLen(m))
+			}
+		}
+	}
+}
+
+func TestSafeTokenCount(t *testing.T) {
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{math.Ldexp(1, -32), math.Ldexp(1, 48) * math.Sqrt2}, // sqrt(2^129 * 2^-32)
+		{1e-18, 2.6087635650665564e10},
+		{1e-12, 2.6087635650665564e13},
+		{0.01, 2.615321040553087e18},
+	}
+	for _, tt := range tests {
+		got := float64(SafeTokenCount(tt.p))
+		if math.Abs(got-tt.want)/tt.want > 1e-9 {
+			t.Errorf("SafeTokenCount(%g) = %g, want %g", tt.p, got, tt.want)
+		}
+	}
+	for _, p := range []float64{0, -1, math.NaN()} {
+		if got := SafeTokenCount(p); got != 0 {
+			t.Errorf("SafeTokenCount(%g) = %d, want 0", p, got)
+		}
+	}
+	for _, p := range []float64{0.5, 1, 2} {
+		if got := SafeTokenCount(p); got != math.MaxUint64 {
+			t.Errorf("SafeTokenCount(%g) = %d, want %d", p, got, uint64(math.MaxUint64))
+		}
+	}
+	if SafeTokenCount(1e-9) <= SafeTokenCount(1e-12) {
+		t.Error("SafeTokenCount is not increasing")
+	}
+}
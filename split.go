@@ -0,0 +1,111 @@
+package fernet
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Split messages are an extension to the Fernet spec. Each part is a
+// token with its own version byte and the header
+//
+//	message ID (16 bytes) || index (4 bytes) || count (4 bytes)
+//
+// which the HMAC covers, so a part cannot be moved to another message
+// or to another position without detection.
+const (
+	splitIDLen     = 16
+	splitHeaderLen = splitIDLen + 4 + 4
+)
+
+// EncryptSplit splits msg into parts of at most chunkSize bytes and
+// encrypts each as a separate token. Every token records a random ID
+// shared by all the parts, the part's index, and the number of parts,
+// so DecryptJoin can reassemble the message from tokens received in any
+// order, even with duplicates, as over an unreliable transport. An
+// empty message has a single, empty part.
+//
+// Unlike a TokenStreamWriter's output, each token is self-describing.
+// The tokens have their own version byte and can only be decrypted by
+// DecryptJoin.
+func EncryptSplit(msg, secret string, now time.Time, chunkSize int) ([]string, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("fernet: chunk size must be positive")
+	}
+	count := (len(msg) + chunkSize - 1) / chunkSize
+	if count == 0 {
+		count = 1
+	}
+	if uint64(count) > 1<<32-1 {
+		return nil, ErrMessageTooLarge
+	}
+	header := make([]byte, splitHeaderLen)
+	if _, err := rand.Read(header[:splitIDLen]); err != nil {
+		return nil, fmt.Errorf("fernet: failed to generate message ID: %v", err)
+	}
+	binary.BigEndian.PutUint32(header[splitIDLen+4:], uint32(count))
+	tokens := make([]string, count)
+	for i := range tokens {
+		binary.BigEndian.PutUint32(header[splitIDLen:], uint32(i))
+		part := msg[i*chunkSize:]
+		if len(part) > chunkSize {
+			part = part[:chunkSize]
+		}
+		var err error
+		if tokens[i], err = seal(&format{version: versionSplit}, header, part, secret, now, randomIV); err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// DecryptJoin decrypts tokens created by EncryptSplit, in any order,
+// and returns the reassembled message. Duplicate tokens are ignored.
+// Every token must be valid, all must belong to the same message, and
+// none of the parts may be missing. See Decrypt.
+func DecryptJoin(tokens []string, secret string, now time.Time, ttl time.Duration) (string, error) {
+	if len(tokens) == 0 {
+		return "", errors.New("fernet: no tokens")
+	}
+	var (
+		id    []byte
+		parts []string
+		seen  []bool
+	)
+	for i, token := range tokens {
+		msg, ts, header, err := open(&format{version: versionSplit}, splitHeaderLen, token, secret)
+		if err != nil {
+			return "", fmt.Errorf("fernet: token %d: %w", i, err)
+		}
+		if err := checkAge(ts, now, ttl); err != nil {
+			return "", fmt.Errorf("fernet: token %d: %w", i, err)
+		}
+		index := int(binary.BigEndian.Uint32(header[splitIDLen:]))
+		count := int(binary.BigEndian.Uint32(header[splitIDLen+4:]))
+		if id == nil {
+			if count > len(tokens) {
+				return "", fmt.Errorf("fernet: split message has %d parts, but only %d tokens were given", count, len(tokens))
+			}
+			id = header[:splitIDLen]
+			parts = make([]string, count)
+			seen = make([]bool, count)
+		} else if string(header[:splitIDLen]) != string(id) || count != len(parts) {
+			return "", fmt.Errorf("fernet: token %d belongs to a different message", i)
+		}
+		if index >= len(parts) {
+			return "", fmt.Errorf("fernet: token %d has index %d of %d", i, index, len(parts))
+		}
+		if !seen[index] {
+			parts[index], seen[index] = string(msg), true
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("fernet: split message is missing part %d", i)
+		}
+	}
+	return strings.Join(parts, ""), nil
+}
@@ -0,0 +1,85 @@
+package fernet
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptSplit(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Date(2017, 9, 13, 16, 45, 18, 0, time.UTC)
+	msg := strings.Repeat("0123456789", 10)
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range []struct {
+		msg       string
+		chunkSize int
+		parts     int
+	}{
+		{msg, 10, 10},
+		{msg, 7, 15},
+		{msg, 100, 1},
+		{msg, 1000, 1},
+		{"", 10, 1},
+	} {
+		tokens, err := EncryptSplit(tt.msg, secret, now, tt.chunkSize)
+		if err != nil {
+			t.Fatalf("chunk size %d: encrypt error: %s", tt.chunkSize, err)
+		}
+		if len(tokens) != tt.parts {
+			t.Fatalf("chunk size %d: got %d tokens, want %d", tt.chunkSize, len(tokens), tt.parts)
+		}
+		// Shuffle the tokens and add some duplicates.
+		in := append([]string(nil), tokens...)
+		for i := 0; i < len(tokens); i += 3 {
+			in = append(in, tokens[i])
+		}
+		rng.Shuffle(len(in), func(i, j int) { in[i], in[j] = in[j], in[i] })
+		got, err := DecryptJoin(in, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("chunk size %d: decrypt error: %s", tt.chunkSize, err)
+		}
+		if got != tt.msg {
+			t.Fatalf("chunk size %d: got %q, want %q", tt.chunkSize, got, tt.msg)
+		}
+		// No standard decoder accepts a part.
+		if _, err := Decrypt(tokens[0], secret, now, time.Minute); err != ErrWrongVersion {
+			t.Fatalf("got error %v from Decrypt, want %v", err, ErrWrongVersion)
+		}
+	}
+
+	tokens, err := EncryptSplit(msg, secret, now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := EncryptSplit(msg, secret, now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := append(append([]string(nil), tokens[:5]...), other[5:]...)
+	for _, tt := range []struct {
+		desc   string
+		tokens []string
+	}{
+		{"no tokens", nil},
+		{"missing part", tokens[1:]},
+		{"missing part, with duplicates", append(tokens[1:], tokens[2])},
+		{"parts of different messages", mixed},
+		{"invalid token", append(tokens[:9:9], "bogus")},
+	} {
+		if _, err := DecryptJoin(tt.tokens, secret, now, time.Minute); err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		}
+	}
+	if _, err := DecryptJoin(tokens, secret, now.Add(time.Hour), time.Minute); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrExpired)
+	}
+	if _, err := DecryptJoin(tokens, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw=", now, time.Minute); !errors.Is(err, ErrWrongHMAC) {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	if _, err := EncryptSplit(msg, secret, now, 0); err == nil {
+		t.Fatal("expected an error for a zero chunk size")
+	}
+}
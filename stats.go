@@ -0,0 +1,21 @@
+package fernet
+
+import "sync/atomic"
+
+// Counts the operations performed by a Fernet or Decryptor, for their
+// Stats methods.
+type opStats struct {
+	encrypts, decrypts, failures atomic.Uint64
+}
+
+// Counts a decryption that returned err.
+func (s *opStats) decrypted(err error) {
+	s.decrypts.Add(1)
+	if err != nil {
+		s.failures.Add(1)
+	}
+}
+
+func (s *opStats) get() (encrypts, decrypts, failures uint64) {
+	return s.encrypts.Load(), s.decrypts.Load(), s.failures.Load()
+}
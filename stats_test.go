@@ -0,0 +1,53 @@
+package fernet
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		n      = 50 // per goroutine
+		procs  = 8
+	)
+	now := time.Now()
+	f := NewFernet(secret, nil)
+	kr := NewKeyRing()
+	if err := kr.Add("one", secret); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecryptor(kr, nil)
+	good, _ := Encrypt("hello", secret, now)
+
+	var wg sync.WaitGroup
+	for p := 0; p < procs; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if _, err := f.Encrypt("hello", now); err != nil {
+					t.Error(err)
+				}
+				f.Decrypt(good, now, time.Minute)
+				f.Decrypt("garbage", now, time.Minute)
+				d.Decrypt(good, now, time.Minute)
+				d.Decrypt("garbage", now, time.Minute)
+				// Reading concurrently with updates must be safe.
+				f.Stats()
+				d.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	e, dec, fail := f.Stats()
+	if e != procs*n || dec != 2*procs*n || fail != procs*n {
+		t.Fatalf("Fernet.Stats() = (%d, %d, %d), want (%d, %d, %d)", e, dec, fail, procs*n, 2*procs*n, procs*n)
+	}
+	e, dec, fail = d.Stats()
+	if e != 0 || dec != 2*procs*n || fail != procs*n {
+		t.Fatalf("Decryptor.Stats() = (%d, %d, %d), want (%d, %d, %d)", e, dec, fail, 0, 2*procs*n, procs*n)
+	}
+}
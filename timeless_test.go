@@ -0,0 +1,47 @@
+package fernet
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestEncryptTimeless(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	for _, msg := range []string{"", "hello", "0123456789abcdef0123456789abcdef"} {
+		tok, err := EncryptTimeless(msg, secret)
+		if err != nil {
+			t.Fatalf("encrypt error: %s", err)
+		}
+		raw, _ := base64.URLEncoding.DecodeString(tok)
+		if raw[0] != versionTimeless {
+			t.Fatalf("got version %#x, want %#x", raw[0], versionTimeless)
+		}
+		for _, b := range raw[tsOffset : tsOffset+tsLen] {
+			if b != 0 {
+				t.Fatalf("timestamp is not zero: %x", raw[tsOffset:tsOffset+tsLen])
+			}
+		}
+		got, err := DecryptTimeless(tok, secret)
+		if err != nil {
+			t.Fatalf("decrypt error: %s", err)
+		}
+		if got != msg {
+			t.Fatalf("wrong message: got %q, want %q", got, msg)
+		}
+		if _, err := Decrypt(tok, secret, time.Unix(0, 0), time.Minute); err == nil {
+			t.Fatal("standard Decrypt accepted a timeless token")
+		}
+	}
+	tok, _ := EncryptTimeless("hello", secret)
+	if _, err := DecryptTimeless(tok, "wGknIOZNpk-KFe5_t5gxH6Eac9gxTv6SlOHVJnSyEVw="); err != ErrWrongHMAC {
+		t.Fatalf("got error %v, want %v", err, ErrWrongHMAC)
+	}
+	std, err := Encrypt("hello", secret, time.Now())
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptTimeless(std, secret); err != ErrWrongVersion {
+		t.Fatalf("got error %v, want %v", err, ErrWrongVersion)
+	}
+}
@@ -0,0 +1,59 @@
+package fernet
+
+import (
+	"fmt"
+	"time"
+)
+
+// A TimeSource supplies the time recorded in new tokens. Unlike a plain
+// time.Time, it can fail, e.g. when a trusted time service is
+// unreachable, in which case no token is created rather than one with
+// an untrusted timestamp. Implementations must be safe for concurrent
+// use.
+type TimeSource interface {
+	Now() (time.Time, error)
+}
+
+// LocalTimeSource is a TimeSource that returns the local clock's time.
+// It never fails.
+type LocalTimeSource struct{}
+
+// Now implements TimeSource.
+func (LocalTimeSource) Now() (time.Time, error) {
+	return time.Now(), nil
+}
+
+// EncryptWithTimeSource is like Encrypt but obtains the token's
+// timestamp from src. If src fails, the error wraps src's error and no
+// token is created.
+func EncryptWithTimeSource(msg, secret string, src TimeSource) (string, error) {
+	now, err := src.Now()
+	if err != nil {
+		return "", fmt.Errorf("fernet: failed to get time: %w", err)
+	}
+	return Encrypt(msg, secret, now)
+}
+
+// A Clock supplies the current time. Unlike a TimeSource, it cannot
+// fail. Implementations must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that returns the local clock's time.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// EncryptWithClock is like Encrypt but takes the token's timestamp from
+// clock.
+func EncryptWithClock(msg, secret string, clock Clock) (string, error) {
+	return Encrypt(msg, secret, clock.Now())
+}
+
+// DecryptWithClock is like Decrypt but takes the current time from
+// clock.
+func DecryptWithClock(token, secret string, clock Clock, ttl time.Duration) (string, error) {
+	return Decrypt(token, secret, clock.Now(), ttl)
+}
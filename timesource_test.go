@@ -0,0 +1,92 @@
+package fernet
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// A TimeSource that returns a fixed time or error.
+type fixedTimeSource struct {
+	t   time.Time
+	err error
+}
+
+func (s fixedTimeSource) Now() (time.Time, error) { return s.t, s.err }
+
+func TestEncryptWithTimeSource(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	tok, err := EncryptWithTimeSource("hello", secret, fixedTimeSource{t: issued})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	msg, md, err := DecryptMetadata(tok, secret, issued, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	if msg != "hello" || !md.Timestamp.Equal(issued) {
+		t.Fatalf("got (%q, %v), want (%q, %v)", msg, md.Timestamp, "hello", issued)
+	}
+
+	errNTP := errors.New("ntp unavailable")
+	tok, err = EncryptWithTimeSource("hello", secret, fixedTimeSource{err: errNTP})
+	if !errors.Is(err, errNTP) {
+		t.Fatalf("got error %v, want %v", err, errNTP)
+	}
+	if tok != "" {
+		t.Fatalf("got token %q despite a failing time source", tok)
+	}
+
+	tok, err = EncryptWithTimeSource("hello", secret, LocalTimeSource{})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := Decrypt(tok, secret, time.Now(), time.Minute); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+}
+
+// A Clock whose time is set by the test.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func TestClock(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	issued := time.Date(2017, time.September, 13, 16, 45, 18, 0, time.UTC)
+	clock := &fakeClock{t: issued}
+	tok, err := EncryptWithClock("hello", secret, clock)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	tests := []struct {
+		desc    string
+		now     time.Time
+		wantErr error
+	}{
+		{"at issue", issued, nil},
+		{"within TTL", issued.Add(time.Minute), nil},
+		{"expired", issued.Add(time.Minute + time.Second), ErrExpired},
+		{"small skew", issued.Add(-maxClockSkew), nil},
+		{"large skew", issued.Add(-maxClockSkew - time.Second), ErrClockSkew},
+	}
+	for _, tt := range tests {
+		clock.t = tt.now
+		msg, err := DecryptWithClock(tok, secret, clock, time.Minute)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: got error %v, want %v", tt.desc, err, tt.wantErr)
+		}
+		if err == nil && msg != "hello" {
+			t.Fatalf("%s: wrong message: got %q, want %q", tt.desc, msg, "hello")
+		}
+	}
+
+	tok, err = EncryptWithClock("hello", secret, SystemClock{})
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	if _, err := DecryptWithClock(tok, secret, SystemClock{}, time.Minute); err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+}
@@ -0,0 +1,212 @@
+package fernet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// NewTokenReader decrypts token and returns a reader of the resulting
+// plaintext. Because the token is fully verified before
+// NewTokenReader returns, reads never observe unauthenticated data.
+func NewTokenReader(token, secret string, now time.Time, ttl time.Duration) (io.Reader, error) {
+	msg, _, err := decrypt(token, secret, &DecryptOptions{Now: now, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(msg), nil
+}
+
+// EncryptFrom is like Encrypt but reads the message, which must be
+// exactly n bytes long, from r. The message is read straight into the
+// token's buffer, with no intermediate copy. If r holds fewer than n
+// bytes, EncryptFrom returns io.ErrUnexpectedEOF; anything after the
+// first n bytes is left unread.
+func EncryptFrom(r io.Reader, n int, secret string, now time.Time) (string, error) {
+	if n < 0 {
+		return "", errors.New("fernet: negative message length")
+	}
+	if err := checkMessageLen(n, 0); err != nil {
+		return "", err
+	}
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return "", err
+	}
+	tok := make([]byte, paddedLen(n)+fixedLen)
+	if _, err := io.ReadFull(r, tok[msgOffset:msgOffset+n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	padTail(tok[msgOffset:], n)
+	block, _ := aes.NewCipher(encryptionKey)
+	return encryptPadded(tok, hmac.New(sha256.New, signingKey), block, &EncryptOptions{Now: now}, randomIV)
+}
+
+// NewTokenWriter returns a writer that accumulates everything written
+// to it and, when closed, encrypts it as a single token and writes the
+// token to w. Because the token is created only on Close, the entire
+// message is buffered in memory.
+func NewTokenWriter(w io.Writer, secret string, now time.Time) io.WriteCloser {
+	return &tokenWriter{w: w, secret: secret, now: now}
+}
+
+type tokenWriter struct {
+	w      io.Writer
+	secret string
+	now    time.Time
+	buf    bytes.Buffer
+	closed bool
+}
+
+var errWriterClosed = errors.New("fernet: write to closed token writer")
+
+func (tw *tokenWriter) Write(p []byte) (int, error) {
+	if tw.closed {
+		return 0, errWriterClosed
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *tokenWriter) Close() error {
+	if tw.closed {
+		return errWriterClosed
+	}
+	tw.closed = true
+	tok, err := Encrypt(tw.buf.String(), tw.secret, tw.now)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(tw.w, tok)
+	return err
+}
+
+// NewStreamingTokenWriter is like NewTokenWriter but encrypts and signs
+// the message incrementally as it is written, so a message of any size
+// is encrypted using a small, constant amount of memory. The encoded
+// token is written to w as it is produced; it is complete once Close
+// returns. The result is always a single standard Fernet token, which
+// Decrypt accepts, but note that decrypting it still requires holding
+// the whole token in memory, since its signature must be verified first.
+func NewStreamingTokenWriter(w io.Writer, secret string, now time.Time) (io.WriteCloser, error) {
+	signingKey, encryptionKey, err := extractKeys(secret)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := aes.NewCipher(encryptionKey)
+	// Write the version, timestamp, and IV, which are known up front.
+	var header [msgOffset]byte
+	header[0] = version
+	binary.BigEndian.PutUint64(header[tsOffset:], uint64(now.Unix()))
+	if err := randomIV(header[ivOffset:]); err != nil {
+		return nil, fmt.Errorf("fernet: failed to generate IV: %v", err)
+	}
+	sw := &streamingTokenWriter{
+		enc: base64.NewEncoder(base64.URLEncoding, w),
+		mac: hmac.New(sha256.New, signingKey),
+		cbc: cipher.NewCBCEncrypter(block, header[ivOffset:]),
+	}
+	_, _ = sw.mac.Write(header[:])
+	if _, err := sw.enc.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+type streamingTokenWriter struct {
+	enc    io.WriteCloser // base64 encoder writing to the destination
+	mac    hash.Hash
+	cbc    cipher.BlockMode
+	buf    [4096]byte // plaintext awaiting encryption
+	n      int        // number of bytes in buf
+	err    error      // sticky write error
+	closed bool
+}
+
+func (sw *streamingTokenWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errWriterClosed
+	}
+	written := 0
+	for len(p) > 0 && sw.err == nil {
+		c := copy(sw.buf[sw.n:], p)
+		sw.n += c
+		p = p[c:]
+		written += c
+		// Keep a partial buffer, since the last block must be padded.
+		if sw.n == len(sw.buf) {
+			sw.flush()
+		}
+	}
+	return written, sw.err
+}
+
+// ReadFrom implements io.ReaderFrom, so io.Copy reads plaintext from r
+// straight into the writer's buffer instead of through a separate one.
+func (sw *streamingTokenWriter) ReadFrom(r io.Reader) (int64, error) {
+	if sw.closed {
+		return 0, errWriterClosed
+	}
+	var total int64
+	for sw.err == nil {
+		n, err := r.Read(sw.buf[sw.n:])
+		sw.n += n
+		total += int64(n)
+		if sw.n == len(sw.buf) {
+			sw.flush()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, sw.err
+}
+
+// Encrypts, signs, and writes the contents of buf, which must be a
+// multiple of the block size.
+func (sw *streamingTokenWriter) flush() {
+	text := sw.buf[:sw.n]
+	sw.n = 0
+	sw.cbc.CryptBlocks(text, text)
+	_, _ = sw.mac.Write(text)
+	_, sw.err = sw.enc.Write(text)
+}
+
+func (sw *streamingTokenWriter) Close() error {
+	if sw.closed {
+		return errWriterClosed
+	}
+	sw.closed = true
+	if sw.err != nil {
+		return sw.err
+	}
+	// Pad the final block; buf is never full here, so there is room.
+	padding := aes.BlockSize - sw.n%aes.BlockSize
+	for i := 0; i < padding; i++ {
+		sw.buf[sw.n+i] = byte(padding)
+	}
+	sw.n += padding
+	sw.flush()
+	if sw.err != nil {
+		return sw.err
+	}
+	var sum [sha256.Size]byte
+	if _, err := sw.enc.Write(sw.mac.Sum(sum[:0])); err != nil {
+		return err
+	}
+	return sw.enc.Close()
+}
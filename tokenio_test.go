@@ -0,0 +1,223 @@
+package fernet
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenReader(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	tok, err := Encrypt(`{"name":"gopher","age":7}`, secret, now)
+	if err != nil {
+		t.Fatalf("encrypt error: %s", err)
+	}
+	r, err := NewTokenReader(tok, secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	var v struct {
+		Name string
+		Age  int
+	}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		t.Fatalf("json error: %s", err)
+	}
+	if v.Name != "gopher" || v.Age != 7 {
+		t.Fatalf("wrong value: %+v", v)
+	}
+	if _, err := NewTokenReader(tok, secret, now.Add(time.Hour), time.Minute); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncryptFrom(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, tt := range []struct {
+		in   string
+		n    int
+		want string // message, or the empty string if an error is expected
+		rest string // left unread
+	}{
+		{"exactly16bytes!!", 16, "exactly16bytes!!", ""},
+		{"hello", 5, "hello", ""},
+		{"hello, world", 5, "hello", ", world"},
+		{"", 0, "", ""},
+		{"tail", 0, "", "tail"},
+	} {
+		r := strings.NewReader(tt.in)
+		tok, err := EncryptFrom(r, tt.n, secret, now)
+		if err != nil {
+			t.Fatalf("%q, %d: encrypt error: %s", tt.in, tt.n, err)
+		}
+		msg, err := Decrypt(tok, secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("%q, %d: decrypt error: %s", tt.in, tt.n, err)
+		}
+		if msg != tt.want {
+			t.Fatalf("%q, %d: got %q, want %q", tt.in, tt.n, msg, tt.want)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != tt.rest {
+			t.Fatalf("%q, %d: left %q unread, want %q", tt.in, tt.n, rest, tt.rest)
+		}
+	}
+	for _, tt := range []struct {
+		in string
+		n  int
+	}{{"hello", 6}, {"", 1}} {
+		if _, err := EncryptFrom(strings.NewReader(tt.in), tt.n, secret, now); err != io.ErrUnexpectedEOF {
+			t.Fatalf("%q, %d: got error %v, want %v", tt.in, tt.n, err, io.ErrUnexpectedEOF)
+		}
+	}
+	if _, err := EncryptFrom(strings.NewReader("hello"), -1, secret, now); err == nil {
+		t.Fatal("expected an error for a negative length")
+	}
+	if _, err := EncryptFrom(strings.NewReader("hello"), 5, "bogus", now); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestTokenWriter(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	type payload struct {
+		Name string
+		Tags []string
+	}
+	now := time.Now()
+	in := payload{Name: "gopher", Tags: []string{"a", "b"}}
+	var buf bytes.Buffer
+	tw := NewTokenWriter(&buf, secret, now)
+	if err := json.NewEncoder(tw).Encode(in); err != nil {
+		t.Fatalf("json error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("token written before Close")
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	if _, err := tw.Write([]byte("x")); err == nil {
+		t.Fatal("expected an error writing after Close")
+	}
+	r, err := NewTokenReader(buf.String(), secret, now, time.Minute)
+	if err != nil {
+		t.Fatalf("decrypt error: %s", err)
+	}
+	var out payload
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		t.Fatalf("json error: %s", err)
+	}
+	if out.Name != in.Name || len(out.Tags) != 2 || out.Tags[0] != "a" || out.Tags[1] != "b" {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestStreamingTokenWriter(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	// Exercise sizes around the block size and the internal buffer.
+	for _, n := range []int{0, 1, 15, 16, 17, 4095, 4096, 4097, 10000} {
+		msg := strings.Repeat("x", n)
+		var buf bytes.Buffer
+		sw, err := NewStreamingTokenWriter(&buf, secret, now)
+		if err != nil {
+			t.Fatalf("NewStreamingTokenWriter error: %s", err)
+		}
+		// Write in uneven pieces.
+		for p := msg; len(p) > 0; {
+			c := 7
+			if c > len(p) {
+				c = len(p)
+			}
+			if _, err := io.WriteString(sw, p[:c]); err != nil {
+				t.Fatalf("write error: %s", err)
+			}
+			p = p[c:]
+		}
+		if err := sw.Close(); err != nil {
+			t.Fatalf("close error: %s", err)
+		}
+		if _, err := sw.Write([]byte("x")); err == nil {
+			t.Fatal("expected an error writing after Close")
+		}
+		if got, want := buf.Len(), EncodedTokenLen(n); got != want {
+			t.Fatalf("n=%d: wrong token length: got %d, want %d", n, got, want)
+		}
+		out, err := Decrypt(buf.String(), secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("n=%d: decrypt error: %s", n, err)
+		}
+		if out != msg {
+			t.Fatalf("n=%d: wrong message", n)
+		}
+	}
+	if _, err := NewStreamingTokenWriter(io.Discard, "bad", now); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestStreamingTokenWriterCopy(t *testing.T) {
+	const secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+	now := time.Now()
+	for _, n := range []int{0, 1, 16, 4095, 4096, 4097, 100000} {
+		msg := strings.Repeat("x", n)
+		var buf bytes.Buffer
+		sw, err := NewStreamingTokenWriter(&buf, secret, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := &maxReadReader{r: strings.NewReader(msg)}
+		if written, err := io.Copy(sw, src); err != nil || written != int64(n) {
+			t.Fatalf("n=%d: copy returned (%d, %v)", n, written, err)
+		}
+		if src.max > 4096 {
+			t.Fatalf("n=%d: read into a %d-byte buffer, so ReadFrom was not used", n, src.max)
+		}
+		if err := sw.Close(); err != nil {
+			t.Fatalf("n=%d: close error: %s", n, err)
+		}
+		out, err := Decrypt(buf.String(), secret, now, time.Minute)
+		if err != nil {
+			t.Fatalf("n=%d: decrypt error: %s", n, err)
+		}
+		if out != msg {
+			t.Fatalf("n=%d: wrong message", n)
+		}
+		if _, err := io.Copy(sw, strings.NewReader("x")); err == nil {
+			t.Fatal("expected an error copying after Close")
+		}
+	}
+}
+
+func TestStreamingTokenWriterMemory(t *testing.T) {
+	const (
+		secret = "cw_0x689RpI-jtRR7oE8h_eQsKImvJapLeSbXpwF4e4="
+		total  = 16 << 20
+	)
+	chunk := make([]byte, 64<<10)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	sw, err := NewStreamingTokenWriter(io.Discard, secret, time.Now())
+	if err != nil {
+		t.Fatalf("NewStreamingTokenWriter error: %s", err)
+	}
+	for i := 0; i < total/len(chunk); i++ {
+		if _, err := sw.Write(chunk); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("close error: %s", err)
+	}
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Fatalf("allocated %d bytes to stream %d bytes", alloc, total)
+	}
+}